@@ -1,27 +1,143 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"expvar"
+	"flag"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/JemJasonCorraggio/mao/internal/config"
+	"github.com/JemJasonCorraggio/mao/internal/game"
+	"github.com/JemJasonCorraggio/mao/internal/transport/rest"
+	"github.com/JemJasonCorraggio/mao/internal/transport/sse"
 	"github.com/JemJasonCorraggio/mao/internal/transport/ws"
 )
 
 func main() {
-	http.HandleFunc("/health", func(w http.ResponseWriter, _ *http.Request) {
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		log.Fatalf("invalid configuration: %v", err)
+	}
+	log.Printf("effective configuration:\n%s", cfg)
+
+	var store game.Store = game.NewMemoryStore()
+	if cfg.DataDir != "" {
+		if store, err = game.NewFileStore(cfg.DataDir); err != nil {
+			log.Fatalf("cannot open data directory: %v", err)
+		}
+	}
+	games := game.NewRegistry(cfg.Game, store)
+	restored, err := games.Restore()
+	if err != nil {
+		log.Fatalf("cannot restore games: %v", err)
+	}
+	if restored > 0 {
+		log.Printf("restored %d games", restored)
+	}
+	expvar.Publish("games", expvar.Func(func() interface{} {
+		return games.CountGames()
+	}))
+
+	// the public server gets its own mux: expvar registers /debug/vars on
+	// the default one, which only the admin listener serves
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/health", func(w http.ResponseWriter, _ *http.Request) {
 		w.Write([]byte("ok"))
 	})
 
-	wsHandler := ws.NewHandler()
+	wsHandler := ws.NewHandler(cfg.WS, games)
+
+	mux.HandleFunc("/ws", wsHandler.Handle)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go wsHandler.RunJanitor(ctx, cfg.Expiry, cfg.ExpiryInterval)
+
+	sseHandler := sse.NewHandler(wsHandler, wsHandler.MaxMessageBytes())
+
+	mux.HandleFunc("GET /sse", sseHandler.Stream)
+	mux.HandleFunc("POST /sse/{session}", sseHandler.Send)
 
-	http.HandleFunc("/ws", wsHandler.Handle)
+	restHandler := rest.NewHandler(cfg.REST, games, wsHandler, wsHandler, wsHandler)
 
-	fs := http.FileServer(http.Dir("./web/dist"))
+	mux.HandleFunc("POST /api/games", restHandler.CreateGame)
+	mux.HandleFunc("GET /api/games/{code}", restHandler.GameInfo)
+	mux.HandleFunc("POST /api/games/{code}/join", restHandler.JoinGame)
+	mux.HandleFunc("GET /api/games/{code}/state", restHandler.PlayerState)
+	mux.HandleFunc("POST /api/games/{code}/{op}", restHandler.AdminAction)
+	mux.HandleFunc("GET /api/games/{code}/history", restHandler.History)
+	mux.HandleFunc("GET /api/games/{code}/replay", restHandler.Replay)
+	mux.HandleFunc("GET /api/games/{code}/export", restHandler.Export)
+	mux.HandleFunc("GET /api/games/{code}/snapshot", restHandler.Snapshot)
+	mux.HandleFunc("POST /api/games/import", restHandler.Import)
 
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	fs := http.FileServer(http.Dir(cfg.StaticDir))
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		fs.ServeHTTP(w, r)
 	})
 
-	log.Println("Server starting on :8080")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	server := &http.Server{Addr: cfg.Addr(), Handler: mux}
+	go func() {
+		log.Println("Server starting on " + cfg.Addr())
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	var adminServer *http.Server
+	if cfg.AdminAddr != "" {
+		adminServer = &http.Server{Addr: cfg.AdminAddr, Handler: http.DefaultServeMux}
+		go func() {
+			log.Println("Admin server starting on " + cfg.AdminAddr)
+			if err := adminServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				log.Fatal(err)
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	stop()
+	log.Println("shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	games.Close()
+
+	// the server stops listening at once but waits for open requests, SSE
+	// streams among them, which end when their clients are closed
+	serverDone := make(chan error, 1)
+	go func() { serverDone <- server.Shutdown(shutdownCtx) }()
+
+	// games only come back after a restart when they are saved to disk
+	var reconnectAfter time.Duration
+	if cfg.DataDir != "" {
+		reconnectAfter = cfg.ReconnectAfter
+	}
+	wsHandler.Shutdown(shutdownCtx, reconnectAfter)
+	if err := <-serverDone; err != nil {
+		log.Printf("shutdown: %v", err)
+	}
+	if adminServer != nil {
+		adminServer.Close()
+	}
+
+	if cfg.DataDir != "" {
+		if err := games.Flush(); err != nil {
+			log.Printf("cannot save games: %v", err)
+		}
+	}
+	log.Println("stopped")
 }
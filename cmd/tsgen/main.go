@@ -0,0 +1,265 @@
+// Command tsgen writes the TypeScript definitions of the wire protocol from
+// the message registry in internal/transport/ws, so the web client cannot
+// drift from the server. With -check it only reports whether the file on
+// disk is current, for CI.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/JemJasonCorraggio/mao/internal/game"
+	"github.com/JemJasonCorraggio/mao/internal/transport/ws"
+)
+
+// enums are the closed sets of strings on the wire. Fields refer to them by
+// Go type or through a `ts` struct tag.
+var enums = []struct {
+	Name   string
+	Values []string
+}{
+	{"GameStatus", []string{
+		string(game.GameWaiting),
+		string(game.GameActive),
+		string(game.GameEnded),
+	}},
+	{"ActionType", []string{
+		string(game.ActionPlayCard),
+		string(game.ActionDraw),
+	}},
+	{"ActionResolution", []string{
+		string(game.ResolutionAccept),
+		string(game.ResolutionAcceptWithPenalty),
+		string(game.ResolutionReject),
+	}},
+	{"Permission", []string{
+		string(game.PermResolveActions),
+		string(game.PermApplyPenalties),
+		string(game.PermStartGame),
+		string(game.PermKickPlayers),
+	}},
+	{"EventType", []string{
+		string(game.EventAction),
+		string(game.EventPenalty),
+		string(game.EventKick),
+		string(game.EventRuleSubmitted),
+		string(game.EventResolution),
+		string(game.EventRoundWon),
+		string(game.EventDiscard),
+		string(game.EventSessionEnded),
+		string(game.EventAppealCalled),
+		string(game.EventAppealVote),
+		string(game.EventAppealClosed),
+	}},
+	{"ConnectionQuality", []string{
+		ws.QualityGood,
+		ws.QualityFair,
+		ws.QualityPoor,
+		ws.QualityStale,
+	}},
+}
+
+var rawMessage = reflect.TypeOf(json.RawMessage{})
+
+type generator struct {
+	structs map[string]reflect.Type
+}
+
+func main() {
+	out := flag.String("out", "web/src/protocol.gen.ts", "file to write")
+	check := flag.Bool("check", false, "fail if the file is not up to date instead of writing it")
+	flag.Parse()
+
+	src := generate()
+
+	if *check {
+		current, err := os.ReadFile(*out)
+		if err != nil {
+			log.Fatalf("cannot read %s: %v", *out, err)
+		}
+		if !bytes.Equal(current, src) {
+			log.Fatalf("%s is stale; run go generate ./...", *out)
+		}
+		return
+	}
+
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		log.Fatalf("cannot write %s: %v", *out, err)
+	}
+}
+
+func generate() []byte {
+	g := &generator{structs: make(map[string]reflect.Type)}
+	for _, spec := range ws.ClientMessages() {
+		g.collect(spec.Body)
+	}
+	for _, spec := range ws.ServerMessages() {
+		g.collect(spec.Body)
+	}
+
+	var b bytes.Buffer
+	b.WriteString("// Code generated by cmd/tsgen from internal/transport/ws; DO NOT EDIT.\n\n")
+
+	fmt.Fprintf(&b, "export const PROTOCOL_VERSION = %d;\n", ws.ProtocolVersion)
+	fmt.Fprintf(&b, "export const CAPABILITIES = [%s];\n", quoteAll(ws.Capabilities(), ", "))
+
+	for _, e := range enums {
+		fmt.Fprintf(&b, "\nexport type %s =\n\t| %s;\n", e.Name, quoteAll(e.Values, "\n\t| "))
+	}
+
+	names := make([]string, 0, len(g.structs))
+	for name := range g.structs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		g.writeInterface(&b, name, g.structs[name])
+	}
+
+	b.WriteString("\n// Every client message may carry a requestId, answered by ACK or ERROR.\n")
+	b.WriteString("export type OutgoingMessage =")
+	for _, spec := range ws.ClientMessages() {
+		fmt.Fprintf(&b, "\n\t| ({ type: %q; requestId?: string }", spec.Type)
+		if spec.Body != nil {
+			fmt.Fprintf(&b, " & Omit<%s, \"type\" | \"requestId\">", reflect.TypeOf(spec.Body).Name())
+		}
+		b.WriteString(")")
+	}
+	b.WriteString(";\n")
+
+	b.WriteString("\nexport type ServerMessage =")
+	for _, spec := range ws.ServerMessages() {
+		if spec.Body == nil {
+			fmt.Fprintf(&b, "\n\t| { type: %q }", spec.Type)
+			continue
+		}
+		fmt.Fprintf(&b, "\n\t| { type: %q; payload: %s }", spec.Type, reflect.TypeOf(spec.Body).Name())
+	}
+	b.WriteString(";\n")
+
+	return b.Bytes()
+}
+
+// collect registers v's struct type and every struct reachable from it.
+func (g *generator) collect(v interface{}) {
+	if v != nil {
+		g.collectType(reflect.TypeOf(v))
+	}
+}
+
+func (g *generator) collectType(t reflect.Type) {
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Map:
+		if t != rawMessage {
+			g.collectType(t.Elem())
+		}
+	case reflect.Struct:
+		if _, seen := g.structs[t.Name()]; seen {
+			return
+		}
+		g.structs[t.Name()] = t
+		for _, f := range fields(t) {
+			g.collectType(f.Type)
+		}
+	}
+}
+
+// fields flattens embedded structs the way encoding/json does.
+func fields(t reflect.Type) []reflect.StructField {
+	var out []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			out = append(out, fields(f.Type)...)
+			continue
+		}
+		if f.IsExported() {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+func (g *generator) writeInterface(b *bytes.Buffer, name string, t reflect.Type) {
+	fmt.Fprintf(b, "\nexport interface %s {\n", name)
+	for _, f := range fields(t) {
+		jsonName, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if jsonName == "-" {
+			continue
+		}
+		if jsonName == "" {
+			jsonName = f.Name
+		}
+
+		optional := strings.Contains(opts, "omitempty")
+		tsType := f.Tag.Get("ts")
+		if tsType == "" {
+			tsType = tsTypeOf(f.Type, !optional)
+		}
+
+		if optional {
+			fmt.Fprintf(b, "\t%s?: %s;\n", jsonName, tsType)
+		} else {
+			fmt.Fprintf(b, "\t%s: %s;\n", jsonName, tsType)
+		}
+	}
+	b.WriteString("}\n")
+}
+
+// tsTypeOf maps a Go type to TypeScript. Pointers, slices and maps can be
+// encoded as null unless the field is omitted when empty.
+func tsTypeOf(t reflect.Type, nullable bool) string {
+	if t == rawMessage {
+		return "unknown"
+	}
+	for _, e := range enums {
+		if t.Name() == e.Name {
+			return e.Name
+		}
+	}
+
+	orNull := func(s string) string {
+		if nullable {
+			return s + " | null"
+		}
+		return s
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Pointer:
+		return orNull(tsTypeOf(t.Elem(), false))
+	case reflect.Slice:
+		return orNull(tsTypeOf(t.Elem(), false) + "[]")
+	case reflect.Map:
+		return orNull("Record<string, " + tsTypeOf(t.Elem(), false) + ">")
+	case reflect.Struct:
+		return t.Name()
+	case reflect.Interface:
+		return "unknown"
+	}
+	log.Fatalf("tsgen: unsupported type %s", t)
+	return ""
+}
+
+func quoteAll(values []string, sep string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = fmt.Sprintf("%q", v)
+	}
+	return strings.Join(quoted, sep)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/JemJasonCorraggio/mao/internal/transport/ws"
+)
+
+// TestGeneratedFileIsCurrent fails when the ws DTOs changed without
+// running go generate ./...
+func TestGeneratedFileIsCurrent(t *testing.T) {
+	current, err := os.ReadFile("../../web/src/protocol.gen.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(current, generate()) {
+		t.Error("web/src/protocol.gen.ts is stale; run go generate ./...")
+	}
+}
+
+func TestEveryMessageIsGenerated(t *testing.T) {
+	src := string(generate())
+	for _, spec := range append(ws.ClientMessages(), ws.ServerMessages()...) {
+		if !strings.Contains(src, `type: "`+spec.Type+`"`) {
+			t.Errorf("message %s is missing from the generated types", spec.Type)
+		}
+	}
+}
@@ -2,4 +2,4 @@ module github.com/JemJasonCorraggio/mao
 
 go 1.25.4
 
-require github.com/gorilla/websocket v1.5.3 // indirect
+require github.com/gorilla/websocket v1.5.3
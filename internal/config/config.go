@@ -0,0 +1,301 @@
+// Package config assembles the server's settings. Every setting is a flag;
+// it can also come from a JSON config file or an environment variable.
+// Flags given on the command line win over the environment, which wins over
+// the file, which wins over the defaults.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/JemJasonCorraggio/mao/internal/game"
+	"github.com/JemJasonCorraggio/mao/internal/ratelimit"
+	"github.com/JemJasonCorraggio/mao/internal/transport/rest"
+	"github.com/JemJasonCorraggio/mao/internal/transport/ws"
+)
+
+// EnvPrefix starts the environment variable of every flag: -pong-wait is
+// MAO_PONG_WAIT. PORT is also read unprefixed, as set by fly.io.
+const EnvPrefix = "MAO_"
+
+type Config struct {
+	Port      int
+	StaticDir string
+	// AdminAddr serves /debug/vars, apart from the public port; empty
+	// turns it off.
+	AdminAddr string
+	// DataDir keeps event logs and game codes on disk; empty keeps them in
+	// memory.
+	DataDir string
+
+	Expiry         game.ExpiryPolicy
+	ExpiryInterval time.Duration
+
+	Game game.Config
+	// CodeBlocklist holds words added to the built-in blocklist.
+	CodeBlocklist []string
+
+	WS   ws.Options
+	REST rest.Options
+
+	// ShutdownTimeout bounds a graceful stop; ReconnectAfter is the delay
+	// clients are told to wait before reconnecting.
+	ShutdownTimeout time.Duration
+	ReconnectAfter  time.Duration
+}
+
+func Default() Config {
+	return Config{
+		Port:           8080,
+		StaticDir:      "./web/dist",
+		AdminAddr:      "127.0.0.1:9090",
+		Expiry:         game.DefaultExpiryPolicy(),
+		ExpiryInterval: time.Minute,
+		Game:           game.DefaultConfig(),
+		WS:             ws.DefaultOptions(),
+
+		ShutdownTimeout: 10 * time.Second,
+		ReconnectAfter:  5 * time.Second,
+	}
+}
+
+// Load reads the configuration from command-line arguments (without the
+// program name), the environment and the file named by -config.
+func Load(args []string, getenv func(string) string) (Config, error) {
+	c := Default()
+	fs := c.flagSet()
+	file := fs.String("config", "", "JSON file of settings keyed by flag name")
+	if err := fs.Parse(args); err != nil {
+		return c, err
+	}
+
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	if *file == "" {
+		*file = getenv(EnvPrefix + "CONFIG")
+	}
+	if *file != "" {
+		if err := loadFile(fs, *file, explicit); err != nil {
+			return c, err
+		}
+	}
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || explicit[f.Name] || f.Name == "config" {
+			return
+		}
+		name := EnvPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		v := getenv(name)
+		if v == "" && f.Name == "port" {
+			name, v = "PORT", getenv("PORT")
+		}
+		if v != "" {
+			if setErr := fs.Set(f.Name, v); setErr != nil {
+				err = fmt.Errorf("%s: %v", name, setErr)
+			}
+		}
+	})
+	if err != nil {
+		return c, err
+	}
+
+	c.Game.Codes.Blocklist = append(c.Game.Codes.Blocklist, c.CodeBlocklist...)
+	return c, c.Validate()
+}
+
+// loadFile applies a JSON object of flag names to values. Lists may be
+// given as arrays; durations as strings such as "90s".
+func loadFile(fs *flag.FlagSet, path string, explicit map[string]bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var values map[string]interface{}
+	if err := dec.Decode(&values); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	for name, v := range values {
+		if fs.Lookup(name) == nil || name == "config" {
+			return fmt.Errorf("%s: unknown setting %q", path, name)
+		}
+		if explicit[name] {
+			continue
+		}
+
+		value := fmt.Sprint(v)
+		if list, ok := v.([]interface{}); ok {
+			items := make([]string, len(list))
+			for i, item := range list {
+				items[i] = fmt.Sprint(item)
+			}
+			value = strings.Join(items, ",")
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("%s: %s: %v", path, name, err)
+		}
+	}
+	return nil
+}
+
+func (c *Config) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("mao", flag.ContinueOnError)
+
+	fs.IntVar(&c.Port, "port", c.Port, "port to listen on")
+	fs.StringVar(&c.StaticDir, "static-dir", c.StaticDir, "directory of the built web client")
+	fs.StringVar(&c.AdminAddr, "admin-addr", c.AdminAddr, "address serving /debug/vars, kept off the public port; empty disables it")
+	fs.StringVar(&c.DataDir, "data-dir", c.DataDir, "directory to keep event logs and game codes in; empty keeps them in memory")
+
+	fs.DurationVar(&c.Expiry.WaitingTTL, "waiting-ttl", c.Expiry.WaitingTTL, "remove games never started after this long without activity, 0 to keep")
+	fs.DurationVar(&c.Expiry.ActiveTTL, "active-ttl", c.Expiry.ActiveTTL, "remove games in progress after this long without activity, 0 to keep")
+	fs.DurationVar(&c.Expiry.EndedTTL, "ended-ttl", c.Expiry.EndedTTL, "remove ended games after this long, 0 to keep")
+	fs.DurationVar(&c.Expiry.HistoryTTL, "history-ttl", c.Expiry.HistoryTTL, "keep the history of expired games, and their codes, this long, 0 to keep")
+	fs.DurationVar(&c.ExpiryInterval, "expiry-interval", c.ExpiryInterval, "how often to look for expired games")
+
+	fs.IntVar(&c.Game.Codes.Length, "code-length", c.Game.Codes.Length, "characters in a game code")
+	fs.StringVar(&c.Game.Codes.Alphabet, "code-alphabet", c.Game.Codes.Alphabet, "characters game codes are made of, A-Z and 0-9")
+	fs.Var((*listValue)(&c.CodeBlocklist), "code-blocklist", "comma-separated words no game code may contain, in addition to the built-in list")
+	fs.IntVar(&c.Game.HandSize, "hand-size", c.Game.HandSize, "cards dealt to each player at the start of a round")
+	fs.IntVar(&c.Game.RecentEvents, "recent-events", c.Game.RecentEvents, "events shown on the table")
+
+	fs.BoolVar(&c.WS.EnableCompression, "ws-compression", c.WS.EnableCompression, "negotiate permessage-deflate on WebSockets")
+	fs.Int64Var(&c.WS.MaxMessageBytes, "max-message-bytes", c.WS.MaxMessageBytes, "largest inbound client message")
+	fs.Var((*listValue)(&c.WS.AllowedOrigins), "allowed-origins", "comma-separated origins allowed to open WebSockets, or * for any; empty allows only this server")
+	fs.Var((*limitsValue)(&c.WS.ConnLimits), "conn-limits", "comma-separated per-connection message limits by class, e.g. play=10/2s or play=off; unnamed classes keep their defaults")
+	fs.Var((*limitsValue)(&c.WS.IPLimits), "ip-limits", "comma-separated per-IP message limits by class, shared with REST, e.g. create=5/1m")
+	fs.IntVar(&c.WS.MaxGamesPerIP, "max-games-per-ip", c.WS.MaxGamesPerIP, "unfinished games one client IP may hold, 0 for no cap")
+	fs.StringVar(&c.WS.ClientIPHeader, "client-ip-header", c.WS.ClientIPHeader, "header carrying the client IP when behind a proxy, e.g. X-Real-IP")
+	fs.DurationVar(&c.WS.WriteWait, "write-wait", c.WS.WriteWait, "time allowed for each write to a socket")
+	fs.DurationVar(&c.WS.PongWait, "pong-wait", c.WS.PongWait, "how long a silent socket is kept open")
+
+	fs.StringVar(&c.REST.ImportToken, "import-token", c.REST.ImportToken, "secret operators send as a bearer token to import snapshots under their original code")
+
+	fs.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", c.ShutdownTimeout, "how long to wait for clients and requests when stopping")
+	fs.DurationVar(&c.ReconnectAfter, "reconnect-after", c.ReconnectAfter, "delay clients are told to wait before reconnecting after a restart, with -data-dir")
+
+	return fs
+}
+
+func (c Config) Validate() error {
+	if c.Port < 1 || c.Port > 65535 {
+		return errors.New("port must be between 1 and 65535")
+	}
+	if c.StaticDir == "" {
+		return errors.New("static directory is required")
+	}
+	if c.AdminAddr != "" {
+		if _, _, err := net.SplitHostPort(c.AdminAddr); err != nil {
+			return fmt.Errorf("admin address: %v", err)
+		}
+	}
+	if c.ExpiryInterval <= 0 {
+		return errors.New("expiry interval must be positive")
+	}
+	if c.ShutdownTimeout <= 0 || c.ReconnectAfter < 0 {
+		return errors.New("shutdown timeout must be positive and reconnect delay not negative")
+	}
+	if err := c.Expiry.Validate(); err != nil {
+		return err
+	}
+	if err := c.Game.Validate(); err != nil {
+		return err
+	}
+	return c.WS.Validate()
+}
+
+func (c Config) Addr() string {
+	return fmt.Sprintf(":%d", c.Port)
+}
+
+// String lists every setting with its effective value, one per line.
+func (c Config) String() string {
+	var b strings.Builder
+	c.flagSet().VisitAll(func(f *flag.Flag) {
+		value := f.Value.String()
+		if secretFlags[f.Name] && value != "" {
+			value = "(set)"
+		}
+		fmt.Fprintf(&b, "  %s = %s\n", f.Name, value)
+	})
+	return b.String()
+}
+
+// secretFlags are not logged with the rest of the configuration.
+var secretFlags = map[string]bool{"import-token": true}
+
+// listValue is a comma-separated flag.
+type listValue []string
+
+func (l *listValue) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *listValue) Set(v string) error {
+	*l = nil
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// limitsValue is a comma-separated list of class=limit pairs. Setting it
+// changes only the classes named.
+type limitsValue map[string]ratelimit.Limit
+
+func (l *limitsValue) String() string {
+	if l == nil {
+		return ""
+	}
+	classes := make([]string, 0, len(*l))
+	for class := range *l {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+
+	items := make([]string, len(classes))
+	for i, class := range classes {
+		items[i] = class + "=" + (*l)[class].String()
+	}
+	return strings.Join(items, ",")
+}
+
+func (l *limitsValue) Set(v string) error {
+	limits := make(limitsValue, len(*l))
+	for class, limit := range *l {
+		limits[class] = limit
+	}
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		class, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return fmt.Errorf("%q is not of the form class=limit", item)
+		}
+		limit, err := ratelimit.ParseLimit(value)
+		if err != nil {
+			return err
+		}
+		limits[strings.TrimSpace(class)] = limit
+	}
+	*l = limits
+	return nil
+}
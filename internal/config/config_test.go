@@ -0,0 +1,213 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JemJasonCorraggio/mao/internal/game"
+	"github.com/JemJasonCorraggio/mao/internal/ratelimit"
+	"github.com/JemJasonCorraggio/mao/internal/transport/ws"
+)
+
+func TestLimitFlags(t *testing.T) {
+	env := map[string]string{"MAO_IP_LIMITS": "create=1/1h"}
+	c, err := Load([]string{"-conn-limits", "play=20/2s,query=off"}, func(k string) string { return env[k] })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defaults := ws.DefaultOptions()
+	if got, want := c.WS.ConnLimits[ws.ClassPlay], ratelimit.Per(20, 2*time.Second); got != want {
+		t.Errorf("play = %+v, want %+v", got, want)
+	}
+	if !c.WS.ConnLimits[ws.ClassQuery].Unlimited() {
+		t.Errorf("query = %+v, want no limit", c.WS.ConnLimits[ws.ClassQuery])
+	}
+	if got, want := c.WS.ConnLimits[ws.ClassJoin], defaults.ConnLimits[ws.ClassJoin]; got != want {
+		t.Errorf("join = %+v, want the default %+v", got, want)
+	}
+	if got, want := c.WS.IPLimits[ws.ClassCreate], ratelimit.Per(1, time.Hour); got != want {
+		t.Errorf("IP create = %+v, want %+v", got, want)
+	}
+}
+
+func TestLimitFlagsInvalid(t *testing.T) {
+	for _, arg := range []string{"play", "play=10", "chat=1/1s"} {
+		if _, err := Load([]string{"-conn-limits", arg}, func(string) string { return "" }); err == nil {
+			t.Errorf("-conn-limits %s accepted", arg)
+		}
+	}
+}
+
+// writeFile writes a config file and returns its path.
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "mao.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPrecedence(t *testing.T) {
+	file := writeFile(t, `{"pong-wait": "10s"}`)
+
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want time.Duration
+	}{
+		{name: "default", want: ws.DefaultOptions().PongWait},
+		{name: "file", args: []string{"-config", file}, want: 10 * time.Second},
+		{name: "file named by the environment", env: map[string]string{"MAO_CONFIG": file}, want: 10 * time.Second},
+		{
+			name: "environment over file",
+			args: []string{"-config", file},
+			env:  map[string]string{"MAO_PONG_WAIT": "20s"},
+			want: 20 * time.Second,
+		},
+		{
+			name: "flag over environment and file",
+			args: []string{"-config", file, "-pong-wait", "30s"},
+			env:  map[string]string{"MAO_PONG_WAIT": "20s"},
+			want: 30 * time.Second,
+		},
+		{
+			name: "flag over file",
+			args: []string{"-pong-wait", "30s", "-config", file},
+			want: 30 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := Load(tt.args, func(k string) string { return tt.env[k] })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.WS.PongWait != tt.want {
+				t.Errorf("pong wait = %v, want %v", c.WS.PongWait, tt.want)
+			}
+		})
+	}
+}
+
+func TestPortFallback(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want int
+	}{
+		{name: "default", want: 8080},
+		{name: "unprefixed PORT", env: map[string]string{"PORT": "7000"}, want: 7000},
+		{name: "MAO_PORT over PORT", env: map[string]string{"PORT": "7000", "MAO_PORT": "7001"}, want: 7001},
+		{name: "flag over PORT", args: []string{"-port", "7002"}, env: map[string]string{"PORT": "7000"}, want: 7002},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := Load(tt.args, func(k string) string { return tt.env[k] })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Port != tt.want {
+				t.Errorf("port = %d, want %d", c.Port, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileValues(t *testing.T) {
+	file := writeFile(t, `{
+		"allowed-origins": ["https://a.example", "https://b.example"],
+		"code-blocklist": ["ZZZ"],
+		"code-length": 6,
+		"ws-compression": true,
+		"history-ttl": "36h",
+		"conn-limits": "play=5/1s"
+	}`)
+
+	c, err := Load([]string{"-config", file}, func(string) string { return "" })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := c.WS.AllowedOrigins; len(got) != 2 || got[0] != "https://a.example" || got[1] != "https://b.example" {
+		t.Errorf("allowed origins = %v", got)
+	}
+	blocklist := c.Game.Codes.Blocklist
+	if len(blocklist) != len(game.DefaultCodeFormat().Blocklist)+1 || blocklist[len(blocklist)-1] != "ZZZ" {
+		t.Errorf("blocklist = %v, want the built-in list plus ZZZ", blocklist)
+	}
+	if c.Game.Codes.Length != 6 {
+		t.Errorf("code length = %d, want 6", c.Game.Codes.Length)
+	}
+	if !c.WS.EnableCompression {
+		t.Error("compression not enabled")
+	}
+	if c.Expiry.HistoryTTL != 36*time.Hour {
+		t.Errorf("history TTL = %v, want 36h", c.Expiry.HistoryTTL)
+	}
+	if got, want := c.WS.ConnLimits[ws.ClassPlay], ratelimit.Per(5, time.Second); got != want {
+		t.Errorf("play = %+v, want %+v", got, want)
+	}
+}
+
+func TestFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "unknown key", content: `{"prot": 8080}`, wantErr: `unknown setting "prot"`},
+		{name: "config key", content: `{"config": "other.json"}`, wantErr: `unknown setting "config"`},
+		{name: "bad duration", content: `{"pong-wait": 60}`, wantErr: "pong-wait"},
+		{name: "not an object", content: `["port"]`, wantErr: "mao.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load([]string{"-config", writeFile(t, tt.content)}, func(string) string { return "" })
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want one mentioning %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateFailures(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+	}{
+		{name: "port out of range", args: []string{"-port", "70000"}},
+		{name: "no static dir", args: []string{"-static-dir", ""}},
+		{name: "admin address without port", args: []string{"-admin-addr", "localhost"}},
+		{name: "zero expiry interval", args: []string{"-expiry-interval", "0"}},
+		{name: "zero shutdown timeout", args: []string{"-shutdown-timeout", "0"}},
+		{name: "negative reconnect delay", args: []string{"-reconnect-after", "-1s"}},
+		{name: "negative TTL", args: []string{"-active-ttl", "-1m"}},
+		{name: "short codes", args: []string{"-code-length", "2"}},
+		{name: "lower-case alphabet", args: []string{"-code-alphabet", "abc"}},
+		{name: "empty hand", args: []string{"-hand-size", "0"}},
+		{name: "zero pong wait", args: []string{"-pong-wait", "0"}},
+		{name: "bad origin", args: []string{"-allowed-origins", "not a url"}},
+		{name: "negative game cap", args: []string{"-max-games-per-ip", "-1"}},
+		{name: "bad environment value", env: map[string]string{"MAO_PONG_WAIT": "soon"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Load(tt.args, func(k string) string { return tt.env[k] }); err == nil {
+				t.Error("configuration accepted")
+			}
+		})
+	}
+}
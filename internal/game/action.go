@@ -27,6 +27,7 @@ type Action struct {
 	Resolved      bool
 	Resolution    ActionResolution
 	ResolvedBy    string 
+	Overturned    bool
 }
 
 
@@ -0,0 +1,240 @@
+package game
+
+import (
+	"errors"
+	"time"
+)
+
+type Appeal struct {
+	Action   *Action
+	CalledBy string
+	OpenedAt time.Time
+	Deadline time.Time
+	// Votes maps a voter to whether they voted to overturn.
+	Votes    map[string]bool
+	Required int
+}
+
+type handEffect struct {
+	playerID string
+	card     *Card
+	added    bool
+}
+
+// resolutionRecord keeps what a resolution changed so that a successful
+// appeal can undo it without touching anything that happened since.
+type resolutionRecord struct {
+	action           *Action
+	effects          []handEffect
+	prevTopCard      *Card
+	prevLastSuccess  *Action
+	prevLastRejected *Action
+	prevStatus       GameStatus
+	prevWinnerID     string
+	// events are those the resolution recorded, so its share of the
+	// standings can be taken back.
+	events     []Event
+	resolvedAt time.Time
+}
+
+func (g *Game) recordAdded(playerID string, card *Card) {
+	if g.recording == nil {
+		return
+	}
+	g.recording.effects = append(g.recording.effects, handEffect{
+		playerID: playerID,
+		card:     card,
+		added:    true,
+	})
+}
+
+func (g *Game) recordRemoved(playerID string, card *Card) {
+	if g.recording == nil {
+		return
+	}
+	g.recording.effects = append(g.recording.effects, handEffect{
+		playerID: playerID,
+		card:     card,
+	})
+}
+
+func (g *Game) CallAppeal(playerID string) error {
+	if !g.Settings.AppealsEnabled {
+		return errors.New("appeals are disabled")
+	}
+
+	now := time.Now()
+	g.expireAppeal(now)
+
+	if g.Appeal != nil {
+		return errors.New("an appeal is already in progress")
+	}
+
+	record := g.lastResolution
+	if record == nil || now.Sub(record.resolvedAt) > g.Settings.AppealWindow {
+		return errors.New("no resolution to appeal")
+	}
+
+	if _, err := g.findPlayer(playerID); err != nil {
+		return err
+	}
+
+	if playerID == record.action.ResolvedBy {
+		return errors.New("cannot appeal your own resolution")
+	}
+
+	eligible := len(g.appealVoters(record.action))
+	required := int(float64(eligible)*g.Settings.AppealMajority) + 1
+	if required > eligible {
+		required = eligible
+	}
+
+	g.Appeal = &Appeal{
+		Action:   record.action,
+		CalledBy: playerID,
+		OpenedAt: now,
+		Deadline: now.Add(g.Settings.AppealWindow),
+		Votes:    map[string]bool{playerID: true},
+		Required: required,
+	}
+
+	g.pushEvent(Event{
+		Type:      EventAppealCalled,
+		PlayerID:  playerID,
+		ActionID:  record.action.ID,
+		Timestamp: now.Unix(),
+	})
+
+	g.tallyAppeal()
+	return nil
+}
+
+func (g *Game) VoteAppeal(playerID string, overturn bool) error {
+	g.expireAppeal(time.Now())
+
+	if g.Appeal == nil {
+		return errors.New("no appeal in progress")
+	}
+
+	if !g.appealVoters(g.Appeal.Action)[playerID] {
+		return errors.New("not eligible to vote")
+	}
+
+	if _, voted := g.Appeal.Votes[playerID]; voted {
+		return errors.New("already voted")
+	}
+
+	g.Appeal.Votes[playerID] = overturn
+	g.pushEvent(Event{
+		Type:      EventAppealVote,
+		PlayerID:  playerID,
+		ActionID:  g.Appeal.Action.ID,
+		Overturn:  overturn,
+		Timestamp: time.Now().Unix(),
+	})
+
+	g.tallyAppeal()
+	return nil
+}
+
+func (g *Game) appealVoters(a *Action) map[string]bool {
+	voters := make(map[string]bool)
+	for _, p := range g.Players {
+		if p.ID != a.ResolvedBy {
+			voters[p.ID] = true
+		}
+	}
+	return voters
+}
+
+func (g *Game) tallyAppeal() {
+	overturn, uphold := 0, 0
+	for _, v := range g.Appeal.Votes {
+		if v {
+			overturn++
+		} else {
+			uphold++
+		}
+	}
+
+	switch {
+	case overturn >= g.Appeal.Required:
+		g.overturnResolution()
+		g.closeAppeal(true)
+	case len(g.appealVoters(g.Appeal.Action))-uphold < g.Appeal.Required:
+		g.closeAppeal(false)
+	}
+}
+
+func (g *Game) expireAppeal(now time.Time) {
+	if g.Appeal != nil && now.After(g.Appeal.Deadline) {
+		g.closeAppeal(false)
+	}
+}
+
+func (g *Game) closeAppeal(overturned bool) {
+	g.pushEvent(Event{
+		Type:      EventAppealClosed,
+		ActionID:  g.Appeal.Action.ID,
+		Overturn:  overturned,
+		Timestamp: time.Now().Unix(),
+	})
+	g.Appeal = nil
+	g.lastResolution = nil
+}
+
+func (g *Game) overturnResolution() {
+	record := g.lastResolution
+
+	for i := len(record.effects) - 1; i >= 0; i-- {
+		e := record.effects[i]
+		p, err := g.findPlayer(e.playerID)
+		if err != nil {
+			continue
+		}
+		if e.added {
+			for j, c := range p.Hand {
+				if c == e.card {
+					p.Hand = append(p.Hand[:j], p.Hand[j+1:]...)
+					break
+				}
+			}
+		} else {
+			p.Hand = append(p.Hand, e.card)
+		}
+	}
+
+	if g.TopCard == record.action.Card {
+		g.TopCard = record.prevTopCard
+	}
+	if g.LastSuccessfulAction == record.action {
+		g.LastSuccessfulAction = record.prevLastSuccess
+	}
+	if g.LastRejectedAction == record.action {
+		g.LastRejectedAction = record.prevLastRejected
+	}
+	g.Status = record.prevStatus
+	g.WinnerID = record.prevWinnerID
+	g.reverseRoundResult(record)
+
+	record.action.Overturned = true
+}
+
+// reverseRoundResult takes back the standings the resolution scored and, if
+// it ended the round, the round result it produced. Anything scored since is
+// left alone.
+func (g *Game) reverseRoundResult(record *resolutionRecord) {
+	for _, e := range record.events {
+		g.untally(e)
+		if e.Type != EventRoundWon {
+			continue
+		}
+		results := g.Session.Results
+		if n := len(results); n > 0 && results[n-1].Round == g.Session.Round && results[n-1].WinnerID == e.PlayerID {
+			g.Session.Results = results[:n-1]
+		}
+		if g.Session.PendingRuleFrom == e.PlayerID {
+			g.Session.PendingRuleFrom = ""
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package game
+
+import "testing"
+
+func TestOverturnReversesOnlyTheAppealedResolution(t *testing.T) {
+	tests := []struct {
+		name        string
+		action      ActionType
+		resolution  ActionResolution
+		penalty     int
+		challengers []string
+		lastCard    bool
+
+		wantHand   int
+		wantStatus GameStatus
+	}{
+		{
+			name:       "accepted winning play",
+			action:     ActionPlayCard,
+			resolution: ResolutionAccept,
+			lastCard:   true,
+			wantHand:   1,
+			wantStatus: GameActive,
+		},
+		{
+			name:        "rejected play with penalty",
+			action:      ActionPlayCard,
+			resolution:  ResolutionReject,
+			penalty:     2,
+			challengers: []string{"c"},
+			wantHand:    7,
+			wantStatus:  GameActive,
+		},
+		{
+			name:       "accepted draw",
+			action:     ActionDraw,
+			resolution: ResolutionAccept,
+			wantHand:   7,
+			wantStatus: GameActive,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, g := newTestGame(t, "a", "b", "c")
+			g.Settings.AppealsEnabled = true
+
+			// scored before the appealed action and must survive it
+			if err := g.ApplyPenalty("b", 3); err != nil {
+				t.Fatal(err)
+			}
+			b := mustPlayer(t, g, "b")
+			b.Hand = b.Hand[:7]
+			if tt.lastCard {
+				b.Hand = b.Hand[:1]
+			}
+			before := standing(g, "b")
+			beforeChallenger := standing(g, "c")
+
+			a := &Action{ID: "act", PlayerID: "b", Type: tt.action}
+			if tt.action == ActionPlayCard {
+				a.Card = b.Hand[0]
+			}
+			play(t, g, a, tt.resolution, tt.penalty, tt.challengers...)
+
+			if err := g.CallAppeal("b"); err != nil {
+				t.Fatal(err)
+			}
+			if err := g.VoteAppeal("c", true); err != nil {
+				t.Fatal(err)
+			}
+
+			if !a.Overturned {
+				t.Fatal("action not overturned")
+			}
+			if got := len(b.Hand); got != tt.wantHand {
+				t.Errorf("hand = %d cards, want %d", got, tt.wantHand)
+			}
+			if g.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", g.Status, tt.wantStatus)
+			}
+			if got := standing(g, "b"); got != before {
+				t.Errorf("standing = %+v, want %+v", got, before)
+			}
+			if got := standing(g, "c"); got != beforeChallenger {
+				t.Errorf("challenger standing = %+v, want %+v", got, beforeChallenger)
+			}
+			if len(g.Session.Results) != 0 || g.Session.PendingRuleFrom != "" || g.WinnerID != "" {
+				t.Errorf("round result kept: %+v, pending %q, winner %q", g.Session.Results, g.Session.PendingRuleFrom, g.WinnerID)
+			}
+		})
+	}
+}
+
+func TestOverturnKeepsEarlierRounds(t *testing.T) {
+	_, g := newTestGame(t, "a", "b", "c")
+	g.Settings.AppealsEnabled = true
+
+	win := func(id string) *Action {
+		p := mustPlayer(t, g, id)
+		p.Hand = p.Hand[:1]
+		a := &Action{ID: "win-" + id, PlayerID: id, Type: ActionPlayCard, Card: p.Hand[0]}
+		play(t, g, a, ResolutionAccept, 0)
+		return a
+	}
+
+	win("b")
+	if err := g.SubmitWinnerRule("b", "no talking"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.StartNextRound("a"); err != nil {
+		t.Fatal(err)
+	}
+	win("c")
+
+	if err := g.CallAppeal("b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.VoteAppeal("c", true); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := g.Session.Standings["b"].RoundWins; got != 1 {
+		t.Errorf("earlier winner has %d round wins, want 1", got)
+	}
+	if got := g.Session.Standings["c"].RoundWins; got != 0 {
+		t.Errorf("overturned winner has %d round wins, want 0", got)
+	}
+	if len(g.Session.Results) != 1 || g.Session.Results[0].WinnerID != "b" {
+		t.Errorf("results = %+v, want only round 1 won by b", g.Session.Results)
+	}
+	if g.Session.Round != 2 || g.Status != GameActive {
+		t.Errorf("round %d status %s, want round 2 active", g.Session.Round, g.Status)
+	}
+}
+
+func standing(g *Game, id string) Standing {
+	s := g.Session.Standings[id]
+	s.PlayerID = id
+	return s
+}
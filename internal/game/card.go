@@ -15,8 +15,8 @@ var suits = []string{
 }
 
 type Card struct {
-	Rank string 
-	Suit string 
+	Rank string `json:"rank"`
+	Suit string `json:"suit"`
 }
 
 func NewRandomCard() *Card {
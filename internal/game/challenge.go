@@ -0,0 +1,86 @@
+package game
+
+import (
+	"sort"
+	"time"
+)
+
+func (g *Game) applyChallengePolicy(a *Action, resolution ActionResolution) error {
+	policy := g.Settings.ChallengePolicy
+
+	switch resolution {
+	case ResolutionAccept:
+		return g.penalizeAll(a.ChallengedBy, policy.WrongChallengerPenalty)
+	case ResolutionAcceptWithPenalty:
+		// the action stands but was at fault, so neither side was wrong
+		// and nobody is penalized or rewarded
+		return nil
+	case ResolutionReject:
+		if err := g.penalizeAll(a.AcceptedBy, policy.WrongAcceptorPenalty); err != nil {
+			return err
+		}
+		return g.discardAll(a.ChallengedBy, policy.CorrectChallengerDiscard)
+	}
+	return nil
+}
+
+func (g *Game) penalizeAll(players map[string]bool, count int) error {
+	if count == 0 {
+		return nil
+	}
+	for _, pid := range sortedIDs(players) {
+		if err := g.ApplyPenalty(pid, count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *Game) discardAll(players map[string]bool, count int) error {
+	if count == 0 {
+		return nil
+	}
+	for _, pid := range sortedIDs(players) {
+		if err := g.discardCards(pid, count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// discardCards removes random cards from a player's hand as a reward.
+func (g *Game) discardCards(playerID string, count int) error {
+	p, err := g.findPlayer(playerID)
+	if err != nil {
+		return err
+	}
+
+	if len(p.Hand) == 0 {
+		return nil
+	}
+
+	discarded := 0
+	for ; discarded < count && len(p.Hand) > 0; discarded++ {
+		i := g.random().Intn(len(p.Hand))
+		card := p.Hand[i]
+		p.Hand = append(p.Hand[:i], p.Hand[i+1:]...)
+		g.recordRemoved(p.ID, card)
+	}
+
+	g.pushEvent(Event{
+		Type:      EventDiscard,
+		PlayerID:  playerID,
+		Discarded: discarded,
+		Timestamp: time.Now().Unix(),
+	})
+	return nil
+}
+
+func sortedIDs(set map[string]bool) []string {
+	ids := make([]string, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,80 @@
+package game
+
+import "testing"
+
+func TestChallengePolicy(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     ChallengePolicy
+		resolution ActionResolution
+
+		// hand sizes of the acceptor b and challenger c afterwards
+		wantB, wantC int
+	}{
+		{
+			name:       "no policy",
+			resolution: ResolutionAccept,
+			wantB:      7, wantC: 7,
+		},
+		{
+			name:       "accept penalizes challengers",
+			policy:     ChallengePolicy{WrongChallengerPenalty: 2, WrongAcceptorPenalty: 1},
+			resolution: ResolutionAccept,
+			wantB:      7, wantC: 9,
+		},
+		{
+			name:       "reject penalizes acceptors",
+			policy:     ChallengePolicy{WrongChallengerPenalty: 2, WrongAcceptorPenalty: 1},
+			resolution: ResolutionReject,
+			wantB:      8, wantC: 7,
+		},
+		{
+			name:       "accept with penalty spares both sides",
+			policy:     ChallengePolicy{WrongChallengerPenalty: 2, WrongAcceptorPenalty: 1, CorrectChallengerDiscard: 3},
+			resolution: ResolutionAcceptWithPenalty,
+			wantB:      7, wantC: 7,
+		},
+		{
+			name:       "reject rewards challengers",
+			policy:     ChallengePolicy{CorrectChallengerDiscard: 3},
+			resolution: ResolutionReject,
+			wantB:      7, wantC: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, g := newTestGame(t, "a", "b", "c", "d")
+			g.Settings.ChallengePolicy = tt.policy
+
+			d := mustPlayer(t, g, "d")
+			a := &Action{
+				ID:           "act",
+				PlayerID:     "d",
+				Type:         ActionPlayCard,
+				Card:         d.Hand[0],
+				AcceptedBy:   map[string]bool{},
+				ChallengedBy: map[string]bool{},
+			}
+			if err := g.ProposeAction(a); err != nil {
+				t.Fatal(err)
+			}
+			if err := g.AcceptAction("b"); err != nil {
+				t.Fatal(err)
+			}
+			if err := g.ChallengeAction("c"); err != nil {
+				t.Fatal(err)
+			}
+			if err := g.ResolveAction("a", tt.resolution, 0); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := len(mustPlayer(t, g, "b").Hand); got != tt.wantB {
+				t.Errorf("acceptor holds %d cards, want %d", got, tt.wantB)
+			}
+			if got := len(mustPlayer(t, g, "c").Hand); got != tt.wantC {
+				t.Errorf("challenger holds %d cards, want %d", got, tt.wantC)
+			}
+		})
+	}
+}
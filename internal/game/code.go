@@ -0,0 +1,117 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// CodeFormat describes the codes players type to join a game.
+type CodeFormat struct {
+	Length   int
+	Alphabet string
+	// Blocklist holds words no code may contain.
+	Blocklist []string
+	// MaxAttempts bounds how many codes CreateGame tries before giving up,
+	// so a crowded code space fails instead of spinning.
+	MaxAttempts int
+}
+
+// ErrNoGameCode is returned when no free code was found within
+// MaxAttempts.
+var ErrNoGameCode = errors.New("no game code available, try again later")
+
+func DefaultCodeFormat() CodeFormat {
+	return CodeFormat{
+		Length:      4,
+		Alphabet:    "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+		Blocklist:   append([]string(nil), defaultBlocklist...),
+		MaxAttempts: 100,
+	}
+}
+
+var defaultBlocklist = []string{
+	"ANAL", "ANUS", "ARSE", "CLIT", "COCK", "COON", "CUNT", "DICK", "DYKE",
+	"FAG", "FUCK", "GOOK", "HOMO", "JIZZ", "KIKE", "NAZI", "PAKI", "PISS",
+	"POOP", "PORN", "PUSS", "RAPE", "SHAT", "SHIT", "SLUT", "SPIC", "TITS",
+	"TWAT", "WANK", "WHORE",
+}
+
+// Validate requires codes that survive being typed in upper case and put in
+// a URL.
+func (f CodeFormat) Validate() error {
+	if f.Length < 3 || f.Length > 12 {
+		return errors.New("game code length must be between 3 and 12")
+	}
+	seen := make(map[rune]bool)
+	for _, r := range f.Alphabet {
+		if !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9') {
+			return fmt.Errorf("game code alphabet may only hold A-Z and 0-9, not %q", r)
+		}
+		if seen[r] {
+			return fmt.Errorf("game code alphabet repeats %q", r)
+		}
+		seen[r] = true
+	}
+	if len(seen) < 2 {
+		return errors.New("game code alphabet needs at least two characters")
+	}
+	if f.MaxAttempts < 1 {
+		return errors.New("game code attempts must be at least 1")
+	}
+	return nil
+}
+
+func (f CodeFormat) generate() string {
+	var b strings.Builder
+	for i := 0; i < f.Length; i++ {
+		b.WriteByte(f.Alphabet[rand.Intn(len(f.Alphabet))])
+	}
+	return b.String()
+}
+
+func (f CodeFormat) blocked(code string) bool {
+	for _, word := range f.Blocklist {
+		if word != "" && strings.Contains(code, strings.ToUpper(word)) {
+			return true
+		}
+	}
+	return false
+}
+
+// fits reports whether code is one the allocator could have handed out.
+func (f CodeFormat) fits(code string) bool {
+	if len(code) != f.Length || f.blocked(code) {
+		return false
+	}
+	for _, r := range code {
+		if !strings.ContainsRune(f.Alphabet, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// allocateCode finds a code that is not blocked, not held by a live game
+// and not reserved in the store, and reserves it. The caller holds reg.mu.
+func (reg *Registry) allocateCode() (string, error) {
+	f := reg.config.Codes
+	for i := 0; i < f.MaxAttempts; i++ {
+		code := f.generate()
+		if f.blocked(code) {
+			continue
+		}
+		if _, exists := reg.games[code]; exists {
+			continue
+		}
+		reserved, err := reg.store.ReserveCode(code)
+		if err != nil {
+			return "", err
+		}
+		if reserved {
+			return code, nil
+		}
+	}
+	return "", ErrNoGameCode
+}
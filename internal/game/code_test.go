@@ -0,0 +1,208 @@
+package game
+
+import (
+	"errors"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestCodeBlocked(t *testing.T) {
+	f := CodeFormat{Blocklist: []string{"poop", "", "NAZI"}}
+
+	tests := []struct {
+		code string
+		want bool
+	}{
+		{code: "POOP", want: true},
+		{code: "XPOOPX", want: true},
+		{code: "NAZI", want: true},
+		{code: "POPO", want: false},
+		{code: "ABCD", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := f.blocked(tt.code); got != tt.want {
+			t.Errorf("blocked(%q) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestAllocateCode(t *testing.T) {
+	tests := []struct {
+		name string
+		// codes taken before allocating
+		reserved []string
+		live     []string
+		format   CodeFormat
+		want     string
+		wantErr  error
+	}{
+		{
+			name:   "skips blocked codes",
+			format: CodeFormat{Length: 3, Alphabet: "AB", Blocklist: []string{"A"}, MaxAttempts: 1000},
+			want:   "BBB",
+		},
+		{
+			name:     "skips reserved codes",
+			reserved: []string{"AA", "AB", "BA"},
+			format:   CodeFormat{Length: 2, Alphabet: "AB", MaxAttempts: 1000},
+			want:     "BB",
+		},
+		{
+			name:   "skips live games",
+			live:   []string{"AA", "AB", "BB"},
+			format: CodeFormat{Length: 2, Alphabet: "AB", MaxAttempts: 1000},
+			want:   "BA",
+		},
+		{
+			name:    "every code blocked",
+			format:  CodeFormat{Length: 3, Alphabet: "AB", Blocklist: []string{"A", "B"}, MaxAttempts: 50},
+			wantErr: ErrNoGameCode,
+		},
+		{
+			name:     "code space full",
+			reserved: []string{"AA", "AB", "BA", "BB"},
+			format:   CodeFormat{Length: 2, Alphabet: "AB", MaxAttempts: 50},
+			wantErr:  ErrNoGameCode,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.Codes = tt.format
+			reg := NewRegistry(config, NewMemoryStore())
+			for _, code := range tt.reserved {
+				if _, err := reg.store.ReserveCode(code); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, code := range tt.live {
+				reg.games[code] = &Game{ID: code}
+			}
+
+			got, err := reg.allocateCode()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("code = %q, want %q", got, tt.want)
+			}
+			if got != "" {
+				if again, _ := reg.store.ReserveCode(got); again {
+					t.Errorf("allocated code %s was not reserved", got)
+				}
+			}
+		})
+	}
+}
+
+func TestFileStoreReserveCode(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a second process sharing the directory
+	other, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := store.ReserveCode("ABCD"); !ok || err != nil {
+		t.Fatalf("first reservation = %v, %v", ok, err)
+	}
+	if ok, err := store.ReserveCode("ABCD"); ok || err != nil {
+		t.Errorf("second reservation = %v, %v; want false", ok, err)
+	}
+	if ok, err := other.ReserveCode("ABCD"); ok || err != nil {
+		t.Errorf("reservation by another store = %v, %v; want false", ok, err)
+	}
+	if _, err := store.ReserveCode("../ABCD"); err == nil {
+		t.Error("reserved a code outside the directory")
+	}
+
+	if ok, _ := store.ReserveCode("WXYZ"); !ok {
+		t.Fatal("could not reserve a second code")
+	}
+	codes, err := other.Codes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(codes)
+	if len(codes) != 2 || codes[0] != "ABCD" || codes[1] != "WXYZ" {
+		t.Errorf("codes = %v, want [ABCD WXYZ]", codes)
+	}
+
+	if err := store.ReleaseCode("ABCD"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.ReleaseCode("ABCD"); err != nil {
+		t.Errorf("releasing a free code: %v", err)
+	}
+	if ok, err := other.ReserveCode("ABCD"); !ok || err != nil {
+		t.Errorf("reservation after release = %v, %v", ok, err)
+	}
+}
+
+func TestRestoreReleasesOrphanedCodes(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := NewRegistry(DefaultConfig(), store)
+
+	// saved at shutdown, so restored
+	saved, err := reg.CreateGame(&Player{ID: "a", IsAdmin: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, code := range []string{"LOST", "KEPT", "GOOD"} {
+		if _, err := store.ReserveCode(code); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.AppendEvent(code, Event{Seq: 1, Type: EventAction}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// an expired game's history, kept for a while and for good
+	if err := store.RetainHistory("KEPT", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RetainHistory("GOOD", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := NewRegistry(DefaultConfig(), store)
+	if n, err := restarted.Restore(); err != nil || n != 1 {
+		t.Fatalf("restored %d games, err %v; want 1", n, err)
+	}
+
+	codes, err := store.Codes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(codes)
+	want := []string{"GOOD", "KEPT", saved.ID}
+	sort.Strings(want)
+	if len(codes) != len(want) {
+		t.Fatalf("codes = %v, want %v", codes, want)
+	}
+	for i := range want {
+		if codes[i] != want[i] {
+			t.Errorf("codes = %v, want %v", codes, want)
+			break
+		}
+	}
+	if events, _ := store.Events("LOST"); len(events) != 0 {
+		t.Errorf("orphaned game kept %d events", len(events))
+	}
+	if events, _ := store.Events("KEPT"); len(events) != 1 {
+		t.Error("retained history was dropped")
+	}
+}
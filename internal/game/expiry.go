@@ -0,0 +1,147 @@
+package game
+
+import (
+	"errors"
+	"expvar"
+	"log"
+	"time"
+)
+
+// ExpiryPolicy says how long a game is kept after its last activity,
+// depending on how far it got, and how long its history outlives it. A
+// zero TTL keeps such games, or histories, forever.
+type ExpiryPolicy struct {
+	WaitingTTL time.Duration
+	ActiveTTL  time.Duration
+	EndedTTL   time.Duration
+	// HistoryTTL is how long the event log of an expired game stays
+	// available, e.g. for replays; its code is not reused until then.
+	HistoryTTL time.Duration
+}
+
+func DefaultExpiryPolicy() ExpiryPolicy {
+	return ExpiryPolicy{
+		WaitingTTL: 30 * time.Minute,
+		ActiveTTL:  2 * time.Hour,
+		EndedTTL:   15 * time.Minute,
+		HistoryTTL: 7 * 24 * time.Hour,
+	}
+}
+
+func (p ExpiryPolicy) Validate() error {
+	if p.WaitingTTL < 0 || p.ActiveTTL < 0 || p.EndedTTL < 0 || p.HistoryTTL < 0 {
+		return errors.New("game TTLs cannot be negative")
+	}
+	return nil
+}
+
+// ttl is how long g may sit idle. A round that has been won leaves the
+// game ended, but only the end of the session finishes it; between rounds
+// it is kept as long as a game in progress. The caller holds g's lock.
+func (p ExpiryPolicy) ttl(g *Game) time.Duration {
+	switch g.Status {
+	case GameWaiting:
+		return p.WaitingTTL
+	case GameActive:
+		return p.ActiveTTL
+	case GameEnded:
+		if !g.Session.Ended {
+			return p.ActiveTTL
+		}
+		return p.EndedTTL
+	}
+	return 0
+}
+
+var expiredGames = expvar.NewInt("games_expired")
+
+func (g *Game) touch() {
+	g.lastActive.Store(time.Now().UnixNano())
+}
+
+// LastActivity is when the game was created, joined or last recorded an
+// event.
+func (g *Game) LastActivity() time.Time {
+	return time.Unix(0, g.lastActive.Load())
+}
+
+// CountGames returns how many games are held, by status.
+func (reg *Registry) CountGames() map[GameStatus]int {
+	counts := map[GameStatus]int{GameWaiting: 0, GameActive: 0, GameEnded: 0}
+	for _, g := range reg.liveGames() {
+		g.Lock()
+		counts[g.Status]++
+		g.Unlock()
+	}
+	return counts
+}
+
+// ExpireGames removes every game that has been idle longer than the policy
+// allows. Its event log and code are kept for the policy's HistoryTTL; see
+// PurgeHistories. The removed games are returned for the caller to notify
+// their players.
+func (reg *Registry) ExpireGames(p ExpiryPolicy, now time.Time) []*Game {
+	ttls := make(map[*Game]time.Duration)
+	for _, g := range reg.liveGames() {
+		g.Lock()
+		ttls[g] = p.ttl(g)
+		g.Unlock()
+	}
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	var expired []*Game
+	for g, ttl := range ttls {
+		id := g.ID
+		// activity since the game was looked at shows in LastActivity, and
+		// a game may already be gone
+		if ttl <= 0 || now.Sub(g.LastActivity()) <= ttl || reg.games[id] != g {
+			continue
+		}
+		// a zero deadline keeps the history for good, and still tells
+		// Restore the code is not orphaned
+		var until time.Time
+		if p.HistoryTTL > 0 {
+			until = now.Add(p.HistoryTTL)
+		}
+		if err := reg.store.RetainHistory(id, until); err != nil {
+			log.Printf("game %s: cannot schedule history removal: %v", id, err)
+		}
+		delete(reg.games, id)
+		expired = append(expired, g)
+	}
+	expiredGames.Add(int64(len(expired)))
+	return expired
+}
+
+// PurgeHistories drops the event logs of expired games whose retention has
+// passed and frees their codes. It returns the ids purged.
+func (reg *Registry) PurgeHistories(now time.Time) []string {
+	retained, err := reg.store.RetainedHistories()
+	if err != nil {
+		log.Printf("cannot list retained histories: %v", err)
+		return nil
+	}
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	var purged []string
+	for id, until := range retained {
+		// a restored or imported game may have taken the code back
+		if until.IsZero() || now.Before(until) || reg.games[id] != nil {
+			continue
+		}
+		// events go before the code is free, or a new game could inherit them
+		if err := reg.store.DeleteEvents(id); err != nil {
+			log.Printf("game %s: cannot delete events: %v", id, err)
+			continue
+		}
+		if err := reg.store.ReleaseCode(id); err != nil {
+			log.Printf("game %s: cannot release code: %v", id, err)
+		}
+		purged = append(purged, id)
+	}
+	return purged
+}
@@ -0,0 +1,114 @@
+package game
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpiryTTL(t *testing.T) {
+	p := ExpiryPolicy{WaitingTTL: time.Minute, ActiveTTL: time.Hour, EndedTTL: time.Second}
+
+	_, g := newTestGame(t, "a", "b")
+	if got := p.ttl(g); got != time.Hour {
+		t.Errorf("game in progress: ttl = %v, want %v", got, time.Hour)
+	}
+
+	winRound(t, g)
+	if got := p.ttl(g); got != time.Hour {
+		t.Errorf("between rounds: ttl = %v, want the active TTL %v", got, time.Hour)
+	}
+
+	endSession(t, g)
+	if got := p.ttl(g); got != time.Second {
+		t.Errorf("session ended: ttl = %v, want %v", got, time.Second)
+	}
+}
+
+func TestExpireKeepsHistoryUntilPurged(t *testing.T) {
+	reg, g := newTestGame(t, "a", "b")
+	store := reg.store.(*MemoryStore)
+	p := ExpiryPolicy{ActiveTTL: time.Hour, HistoryTTL: 24 * time.Hour}
+	now := time.Now()
+
+	if expired := reg.ExpireGames(p, now); len(expired) != 0 {
+		t.Fatalf("expired %d active games", len(expired))
+	}
+
+	now = now.Add(2 * time.Hour)
+	if expired := reg.ExpireGames(p, now); len(expired) != 1 || expired[0] != g {
+		t.Fatalf("expired %v, want the idle game", expired)
+	}
+	if _, err := reg.GetGame(g.ID); err == nil {
+		t.Error("expired game is still live")
+	}
+	if events, _ := store.Events(g.ID); len(events) == 0 {
+		t.Error("expiry deleted the game's events")
+	}
+	if !store.codes[g.ID] {
+		t.Error("expiry freed the game's code before its history was dropped")
+	}
+
+	if purged := reg.PurgeHistories(now.Add(23 * time.Hour)); len(purged) != 0 {
+		t.Errorf("purged %v before the history TTL", purged)
+	}
+
+	purged := reg.PurgeHistories(now.Add(24 * time.Hour))
+	if len(purged) != 1 || purged[0] != g.ID {
+		t.Fatalf("purged %v, want %s", purged, g.ID)
+	}
+	if events, _ := store.Events(g.ID); len(events) != 0 {
+		t.Errorf("%d events left after the purge", len(events))
+	}
+	if store.codes[g.ID] {
+		t.Error("code still reserved after the purge")
+	}
+	if retained, _ := store.RetainedHistories(); len(retained) != 0 {
+		t.Errorf("retention left behind: %v", retained)
+	}
+}
+
+func TestExpireWithoutHistoryTTLKeepsHistory(t *testing.T) {
+	reg, g := newTestGame(t, "a", "b")
+	p := ExpiryPolicy{ActiveTTL: time.Hour}
+	now := time.Now().Add(2 * time.Hour)
+
+	reg.ExpireGames(p, now)
+	if purged := reg.PurgeHistories(now.Add(1000 * time.Hour)); len(purged) != 0 {
+		t.Errorf("purged %v with no history TTL", purged)
+	}
+	if events, _ := reg.store.Events(g.ID); len(events) == 0 {
+		t.Error("history of the expired game was dropped")
+	}
+	if retained, _ := reg.store.RetainedHistories(); !retained[g.ID].IsZero() || len(retained) != 1 {
+		t.Errorf("retained = %v, want %s kept for good", retained, g.ID)
+	}
+}
+
+func TestFileStoreRetention(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	until := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	if err := store.AppendEvent("ABCD", Event{Seq: 1, Type: EventAction}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RetainHistory("ABCD", until); err != nil {
+		t.Fatal(err)
+	}
+	retained, err := store.RetainedHistories()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !retained["ABCD"].Equal(until) {
+		t.Errorf("retained = %v, want ABCD until %v", retained, until)
+	}
+
+	if err := store.DeleteEvents("ABCD"); err != nil {
+		t.Fatal(err)
+	}
+	if retained, _ := store.RetainedHistories(); len(retained) != 0 {
+		t.Errorf("retention outlived the events: %v", retained)
+	}
+}
@@ -0,0 +1,241 @@
+package game
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileStore keeps event logs, reserved codes and snapshots in a directory,
+// so they survive a restart. Each game's events are a JSON-lines file under
+// events/; each reserved code is an empty file under codes/, created
+// exclusively so two processes sharing the directory cannot claim the same
+// code; snapshots are JSON files under snapshots/. A log kept after its
+// game expired has a file under retained/ holding when it may be dropped.
+type FileStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+func NewFileStore(dir string) (*FileStore, error) {
+	for _, sub := range []string{"events", "retained", "codes", "snapshots"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// path maps a game id to a file, refusing ids that could escape the
+// directory since imported snapshots choose their own.
+func (s *FileStore) path(sub, id, ext string) (string, error) {
+	if id == "" {
+		return "", errors.New("empty game id")
+	}
+	for _, r := range id {
+		if !(r >= 'A' && r <= 'Z') && !(r >= 'a' && r <= 'z') && !(r >= '0' && r <= '9') && r != '-' && r != '_' {
+			return "", fmt.Errorf("game id %q cannot be stored", id)
+		}
+	}
+	return filepath.Join(s.dir, sub, id+ext), nil
+}
+
+func (s *FileStore) AppendEvent(gameID string, e Event) error {
+	path, err := s.path("events", gameID, ".jsonl")
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *FileStore) Events(gameID string) ([]Event, error) {
+	path, err := s.path("events", gameID, ".jsonl")
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("game %s: corrupt event log: %w", gameID, err)
+		}
+		events = append(events, e)
+	}
+	return events, scanner.Err()
+}
+
+func (s *FileStore) DeleteEvents(gameID string) error {
+	path, err := s.path("events", gameID, ".jsonl")
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	retained, _ := s.path("retained", gameID, "")
+	if err := os.Remove(retained); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *FileStore) RetainHistory(gameID string, until time.Time) error {
+	path, err := s.path("retained", gameID, "")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(until.UTC().Format(time.RFC3339)), 0o644)
+}
+
+func (s *FileStore) RetainedHistories() (map[string]time.Time, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, "retained"))
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]time.Time, len(entries))
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(s.dir, "retained", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		until, err := time.Parse(time.RFC3339, strings.TrimSpace(string(data)))
+		if err != nil {
+			return nil, fmt.Errorf("retained/%s: %w", entry.Name(), err)
+		}
+		out[entry.Name()] = until
+	}
+	return out, nil
+}
+
+func (s *FileStore) ReserveCode(code string) (bool, error) {
+	path, err := s.path("codes", code, "")
+	if err != nil {
+		return false, err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, f.Close()
+}
+
+func (s *FileStore) ReleaseCode(code string) error {
+	path, err := s.path("codes", code, "")
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *FileStore) Codes() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, "codes"))
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		out = append(out, entry.Name())
+	}
+	return out, nil
+}
+
+func (s *FileStore) SaveSnapshot(snapshot Snapshot) error {
+	path, err := s.path("snapshots", snapshot.Game.ID, ".json")
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	// write aside and rename, so a crash mid-write leaves no torn snapshot
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *FileStore) Snapshots() ([]Snapshot, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "snapshots", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Snapshot
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var snapshot Snapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		out = append(out, snapshot)
+	}
+	return out, nil
+}
+
+func (s *FileStore) DeleteSnapshot(gameID string) error {
+	path, err := s.path("snapshots", gameID, ".json")
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -2,8 +2,10 @@ package game
 
 import (
 	"errors"
+	"log"
 	"math/rand"
-	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -20,131 +22,113 @@ type EventType string
 const (
 	EventAction  EventType = "ACTION"
 	EventPenalty EventType = "PENALTY"
+	EventKick    EventType = "KICK"
+
+	EventRuleSubmitted EventType = "RULE_SUBMITTED"
+	EventResolution    EventType = "RESOLUTION"
+	EventRoundWon      EventType = "ROUND_WON"
+	EventDiscard       EventType = "DISCARD"
+	EventSessionEnded  EventType = "SESSION_ENDED"
+
+	EventAppealCalled EventType = "APPEAL_CALLED"
+	EventAppealVote   EventType = "APPEAL_VOTE"
+	EventAppealClosed EventType = "APPEAL_CLOSED"
 )
 
 type Event struct {
+	Seq        int64
 	Type   	   EventType
 	PlayerID   string
 	ActionID   string
 	ActionType string
 	Card   	   *Card
 	Penalty    int
+	Discarded  int
+	Overturn   bool
+	Resolution  string
+	Challengers []string
+	Players    []string
+	Dealt      int
 	Timestamp  int64
 }
 
-var games = make(map[string]*Game)
-
 type Game struct {
 	ID            		string
 	Status        		GameStatus
 	Players       		[]*Player
 	AdminID       		string
+	Judges               map[string]map[Permission]bool
 	CurrentAction 		*Action
 	TopCard   	  		*Card
 	WinnerID             string
 	LastSuccessfulAction *Action
+	LastRejectedAction   *Action
 	RecentEvents  		[]Event
+	Settings             Settings
+	Appeal               *Appeal
+	Rulebook             []*Rule
+	Session              Session
+
+	lastResolution       *resolutionRecord
+	recording            *resolutionRecord
+	Seed                 int64
+
+	ruleSeq              int
+	eventSeq             int64
+	rng                  *rand.Rand
+	rngSource            *countingSource
+	lastActive           atomic.Int64
+	registry             *Registry
+	mu                   sync.Mutex
 }
 
-const gameCodeLength = 4
-const gameCodeAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
-
-func generateGameCode() string {
-	rand.Seed(time.Now().UnixNano())
-
-	var b strings.Builder
-	for i := 0; i < gameCodeLength; i++ {
-		b.WriteByte(gameCodeAlphabet[rand.Intn(len(gameCodeAlphabet))])
-	}
-	return b.String()
+// Lock serializes everything done to a game, whichever transport or
+// background task does it. Game methods, and the Registry methods that
+// change a game, expect the caller to hold it.
+func (g *Game) Lock() {
+	g.mu.Lock()
 }
 
-func CreateGame(adminPlayer *Player) (*Game, error) {
-	if adminPlayer == nil {
-		return nil, errors.New("admin player cannot be nil")
-	}
-
-	var gameID string
-	for {
-		gameID = generateGameCode()
-		if _, exists := games[gameID]; !exists {
-			break
-		}
-	}
-
-	game := &Game{
-		ID:      gameID,
-		Status:  GameWaiting,
-		Players: []*Player{adminPlayer},
-		AdminID: adminPlayer.ID,
-	}
-
-	games[gameID] = game
-
-	return game, nil
+func (g *Game) Unlock() {
+	g.mu.Unlock()
 }
 
-func JoinGame(gameID string, player *Player) (*Game, error) {
-	if player == nil {
-		return nil, errors.New("player cannot be nil")
-	}
-
-	game, exists := games[gameID]
-	if !exists {
-		return nil, errors.New("game not found")
-	}
-
-	if game.Status != GameWaiting {
-		return nil, errors.New("game already started")
-	}
-
-	for _, p := range game.Players {
-		if p.ID == player.ID {
-			return nil, errors.New("player already in game")
-		}
-	}
-
-	game.Players = append(game.Players, player)
-
-	return game, nil
-}
-
-func GetGame(gameID string) (*Game, error) {
-	game, ok := games[gameID]
-	if !ok {
-		return nil, errors.New("game not found")
-	}
-	return game, nil
-}
-
-func (g *Game) StartGame(adminID string) error {
+func (g *Game) StartGame(actorID string) error {
 	if g.Status != GameWaiting {
 		return errors.New("game already started")
 	}
 
-	if g.AdminID != adminID {
-		return errors.New("only admin can start game")
+	if !g.HasPermission(actorID, PermStartGame) {
+		return errors.New("not allowed to start game")
 	}
 
 	g.Status = GameActive
+	g.Session.Round = 1
 
-	g.TopCard = NewRandomCard()
+	g.TopCard = g.newCard()
 	g.dealInitialHands()
 	// - emit/broadcast game state
 
 	g.pushEvent(Event{
 		Type:      EventAction,
 		ActionType: "START_GAME",
+		Card:      g.TopCard,
+		Players:   g.seating(),
+		Dealt:     g.handSize(),
 		Timestamp: time.Now().Unix(),
 	})
 
 	return nil
 }
 
+func (g *Game) handSize() int {
+	return g.registry.config.HandSize
+}
+
 func (g *Game) dealInitialHands() {
 	for _, p := range g.Players {
-		for i := 0; i < 7; i++ {
-			p.Hand = append(p.Hand, NewRandomCard())
+		for i := 0; i < g.handSize(); i++ {
+			p.Hand = append(p.Hand, g.newCard())
 		}
 	}
 }
@@ -158,6 +142,12 @@ func (g *Game) ProposeAction(a *Action) error {
 		return errors.New("another action is already pending")
 	}
 
+	g.expireAppeal(time.Now())
+	if g.Appeal != nil {
+		return errors.New("an appeal is in progress")
+	}
+
+	g.lastResolution = nil
 	g.CurrentAction = a
 	return nil
 }
@@ -167,10 +157,21 @@ func (g *Game) ClearAction() {
 }
 
 func (g *Game) pushEvent(e Event) {
-	g.RecentEvents = append(g.RecentEvents, e)
-	if len(g.RecentEvents) > 10 {
-		g.RecentEvents = g.RecentEvents[len(g.RecentEvents)-10:]
+	g.eventSeq++
+	e.Seq = g.eventSeq
+	if g.recording != nil {
+		if e.ActionID == "" {
+			e.ActionID = g.recording.action.ID
+		}
+		g.recording.events = append(g.recording.events, e)
 	}
+	if err := g.registry.store.AppendEvent(g.ID, e); err != nil {
+		log.Printf("game %s: cannot store event: %v", g.ID, err)
+	}
+	g.tally(e)
+	g.touch()
+	g.RecentEvents = append(g.RecentEvents, e)
+	g.trimRecentEvents()
 }
 
 func (g *Game) AcceptAction(playerID string) error {
@@ -208,7 +209,7 @@ func (g *Game) ChallengeAction(playerID string) error {
 }
 
 func (g *Game) ResolveAction(
-	adminID string,
+	actorID string,
 	resolution ActionResolution,
 	penaltyCount int,
 ) error {
@@ -220,27 +221,37 @@ func (g *Game) ResolveAction(
 		return errors.New("no current action")
 	}
 
-	if g.AdminID != adminID {
-		return errors.New("only admin can resolve actions")
+	if !g.HasPermission(actorID, PermResolveActions) {
+		return errors.New("not allowed to resolve actions")
 	}
 
 	if g.CurrentAction.Resolved {
 		return errors.New("action already resolved")
 	}
 
+	if penaltyCount < 0 {
+		return errNegativePenalty
+	}
+
+	record := &resolutionRecord{
+		action:          g.CurrentAction,
+		prevTopCard:     g.TopCard,
+		prevLastSuccess: g.LastSuccessfulAction,
+		prevLastRejected: g.LastRejectedAction,
+		prevStatus:      g.Status,
+		prevWinnerID:    g.WinnerID,
+	}
+	g.recording = record
+	defer func() { g.recording = nil }()
+
 	switch resolution{
 	case ResolutionAccept:
-		if err := g.acceptAction(g.CurrentAction.PlayerID); err != nil {
+		if err := g.acceptAction(g.CurrentAction.PlayerID, resolution); err != nil {
 			return err
 		}
 		g.LastSuccessfulAction = g.CurrentAction
-		for playerID := range g.CurrentAction.ChallengedBy {
-			if err := g.ApplyPenalty(playerID, 1); err != nil {
-				return err
-			}
-		}
 	case ResolutionAcceptWithPenalty:
-		if err := g.acceptAction(g.CurrentAction.PlayerID); err != nil {
+		if err := g.acceptAction(g.CurrentAction.PlayerID, resolution); err != nil {
 			return err
 		}
 		g.LastSuccessfulAction = g.CurrentAction
@@ -248,6 +259,8 @@ func (g *Game) ResolveAction(
 			return err
 		}
 	case ResolutionReject:
+		g.pushActionEvent(g.CurrentAction, resolution)
+		g.LastRejectedAction = g.CurrentAction
 		if err := g.ApplyPenalty(g.CurrentAction.PlayerID, penaltyCount); err != nil {
 			return err
 		}
@@ -255,11 +268,29 @@ func (g *Game) ResolveAction(
 		return errors.New("invalid resolution")
 	}
 
+	if err := g.applyChallengePolicy(g.CurrentAction, resolution); err != nil {
+		return err
+	}
+
 	g.CurrentAction.Resolved = true
 	g.CurrentAction.Resolution = resolution
-	g.CurrentAction.ResolvedBy = adminID
+	g.CurrentAction.ResolvedBy = actorID
+
+	g.pushEvent(Event{
+		Type:        EventResolution,
+		PlayerID:    g.CurrentAction.PlayerID,
+		ActionID:    g.CurrentAction.ID,
+		ActionType:  string(g.CurrentAction.Type),
+		Resolution:  string(resolution),
+		Challengers: sortedIDs(g.CurrentAction.ChallengedBy),
+		Timestamp:   time.Now().Unix(),
+	})
 
 	g.checkForWin()
+	if g.Settings.AppealsEnabled {
+		record.resolvedAt = time.Now()
+		g.lastResolution = record
+	}
 	g.ClearAction()
 
 	return nil
@@ -270,11 +301,20 @@ func (g *Game) checkForWin() {
 		if len(p.Hand) == 0 {
 			g.Status = GameEnded
 			g.WinnerID = p.ID
+			g.endRound(p.ID)
 			return
 		}
 	}
 }
 
+func (g *Game) seating() []string {
+	ids := make([]string, 0, len(g.Players))
+	for _, p := range g.Players {
+		ids = append(ids, p.ID)
+	}
+	return ids
+}
+
 func (g *Game) findPlayer(id string) (*Player, error) {
 	for _, p := range g.Players {
 		if p.ID == id {
@@ -284,7 +324,7 @@ func (g *Game) findPlayer(id string) (*Player, error) {
 	return nil, errors.New("player not found")
 }
 
-func (g *Game) acceptAction(playerID string) error {
+func (g *Game) acceptAction(playerID string, resolution ActionResolution) error {
 	switch g.CurrentAction.Type {
 	case ActionPlayCard:
 		g.TopCard = g.CurrentAction.Card
@@ -296,29 +336,44 @@ func (g *Game) acceptAction(playerID string) error {
 		if err != nil {
 			return err
 		}
-		p.Hand = append(p.Hand, NewRandomCard())
+		card := g.newCard()
+		p.Hand = append(p.Hand, card)
+		g.recordAdded(p.ID, card)
 	default:
 		return errors.New("unsupported action type")
 	}
+	g.pushActionEvent(g.CurrentAction, resolution)
+	return nil
+}
+
+func (g *Game) pushActionEvent(a *Action, resolution ActionResolution) {
 	g.pushEvent(Event{
-		Type:      EventAction,
-		PlayerID:  g.CurrentAction.PlayerID,
-		ActionID:  g.CurrentAction.ID,
-		ActionType: string(g.CurrentAction.Type),
-		Card:      g.CurrentAction.Card,
-		Timestamp: time.Now().Unix(),
+		Type:       EventAction,
+		PlayerID:   a.PlayerID,
+		ActionID:   a.ID,
+		ActionType: string(a.Type),
+		Card:       a.Card,
+		Resolution: string(resolution),
+		Timestamp:  time.Now().Unix(),
 	})
-	return nil
 }
 
+var errNegativePenalty = errors.New("penalty count cannot be negative")
+
 func (g *Game) ApplyPenalty(playerID string, count int) error {
+	if count < 0 {
+		return errNegativePenalty
+	}
+
 	p, err := g.findPlayer(playerID)
 	if err != nil {
 		return err
 	}
 
 	for i := 0; i < count; i++ {
-		p.Hand = append(p.Hand, NewRandomCard())
+		card := g.newCard()
+		p.Hand = append(p.Hand, card)
+		g.recordAdded(p.ID, card)
 	}
 	g.pushEvent(Event{
 		Type:      EventPenalty,
@@ -338,6 +393,7 @@ func (g *Game) removeCardFromHand(playerID string, card Card) error{
 	for i, c := range hand {
 		if c.Rank == card.Rank && c.Suit == card.Suit {
 			p.Hand = append(hand[:i], hand[i+1:]...)
+			g.recordRemoved(p.ID, c)
 			return nil
 		}
 	}
@@ -0,0 +1,54 @@
+package game
+
+import "testing"
+
+// newTestGame starts a game seated with the given players, the first of
+// whom is the admin.
+func newTestGame(t *testing.T, ids ...string) (*Registry, *Game) {
+	t.Helper()
+
+	reg := NewRegistry(DefaultConfig(), NewMemoryStore())
+	g, err := reg.CreateGame(&Player{ID: ids[0], Name: ids[0], IsAdmin: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range ids[1:] {
+		if _, err := reg.JoinGame(g.ID, &Player{ID: id, Name: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := g.StartGame(ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	return reg, g
+}
+
+func mustPlayer(t *testing.T, g *Game, id string) *Player {
+	t.Helper()
+
+	p, err := g.findPlayer(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// play proposes and resolves an action by playerID, challenged by the given
+// players.
+func play(t *testing.T, g *Game, a *Action, resolution ActionResolution, penalty int, challengers ...string) {
+	t.Helper()
+
+	a.AcceptedBy = map[string]bool{}
+	a.ChallengedBy = map[string]bool{}
+	if err := g.ProposeAction(a); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range challengers {
+		if err := g.ChallengeAction(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := g.ResolveAction(g.AdminID, resolution, penalty); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,80 @@
+package game
+
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 200
+)
+
+// HistoryQuery selects a page of a game's event log. After is the cursor
+// returned by the previous page; zero starts from the beginning. Since and
+// Until are unix seconds and zero leaves that side unbounded.
+type HistoryQuery struct {
+	After    int64
+	Limit    int
+	PlayerID string
+	Types    []EventType
+	Since    int64
+	Until    int64
+}
+
+type HistoryPage struct {
+	Events     []Event
+	NextCursor int64
+}
+
+func (q HistoryQuery) matches(e Event) bool {
+	if e.Seq <= q.After {
+		return false
+	}
+	if q.PlayerID != "" && e.PlayerID != q.PlayerID {
+		return false
+	}
+	if q.Since != 0 && e.Timestamp < q.Since {
+		return false
+	}
+	if q.Until != 0 && e.Timestamp > q.Until {
+		return false
+	}
+	if len(q.Types) == 0 {
+		return true
+	}
+	for _, t := range q.Types {
+		if e.Type == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Events returns the game's complete event log.
+func (g *Game) Events() ([]Event, error) {
+	return g.registry.store.Events(g.ID)
+}
+
+func (g *Game) History(q HistoryQuery) (HistoryPage, error) {
+	events, err := g.registry.store.Events(g.ID)
+	if err != nil {
+		return HistoryPage{}, err
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultHistoryLimit
+	}
+	if limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+
+	var page HistoryPage
+	for _, e := range events {
+		if !q.matches(e) {
+			continue
+		}
+		if len(page.Events) == limit {
+			page.NextCursor = page.Events[len(page.Events)-1].Seq
+			break
+		}
+		page.Events = append(page.Events, e)
+	}
+	return page, nil
+}
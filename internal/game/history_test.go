@@ -0,0 +1,115 @@
+package game
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	log := []Event{
+		{Seq: 1, Type: EventAction, PlayerID: "a", Timestamp: 100},
+		{Seq: 2, Type: EventPenalty, PlayerID: "b", Timestamp: 110},
+		{Seq: 3, Type: EventResolution, PlayerID: "a", Timestamp: 120},
+		{Seq: 4, Type: EventPenalty, PlayerID: "a", Timestamp: 130},
+		{Seq: 5, Type: EventAction, PlayerID: "b", Timestamp: 140},
+		{Seq: 6, Type: EventPenalty, PlayerID: "b", Timestamp: 150},
+	}
+
+	tests := []struct {
+		name       string
+		query      HistoryQuery
+		wantSeqs   []int64
+		wantCursor int64
+	}{
+		{name: "whole log", wantSeqs: []int64{1, 2, 3, 4, 5, 6}},
+		{name: "first page", query: HistoryQuery{Limit: 2}, wantSeqs: []int64{1, 2}, wantCursor: 2},
+		{name: "middle page", query: HistoryQuery{After: 2, Limit: 2}, wantSeqs: []int64{3, 4}, wantCursor: 4},
+		{name: "last full page", query: HistoryQuery{After: 4, Limit: 2}, wantSeqs: []int64{5, 6}},
+		{name: "last short page", query: HistoryQuery{After: 4, Limit: 10}, wantSeqs: []int64{5, 6}},
+		{name: "past the end", query: HistoryQuery{After: 6}},
+		{name: "by player", query: HistoryQuery{PlayerID: "b"}, wantSeqs: []int64{2, 5, 6}},
+		{name: "by type", query: HistoryQuery{Types: []EventType{EventPenalty}}, wantSeqs: []int64{2, 4, 6}},
+		{
+			name:     "by several types",
+			query:    HistoryQuery{Types: []EventType{EventAction, EventResolution}},
+			wantSeqs: []int64{1, 3, 5},
+		},
+		{
+			name:       "filtered first page",
+			query:      HistoryQuery{PlayerID: "b", Types: []EventType{EventPenalty}, Limit: 1},
+			wantSeqs:   []int64{2},
+			wantCursor: 2,
+		},
+		{
+			name:     "filtered last page",
+			query:    HistoryQuery{After: 2, PlayerID: "b", Types: []EventType{EventPenalty}, Limit: 1},
+			wantSeqs: []int64{6},
+		},
+		{name: "time range", query: HistoryQuery{Since: 120, Until: 140}, wantSeqs: []int64{3, 4, 5}},
+		{name: "unknown player", query: HistoryQuery{PlayerID: "z"}},
+	}
+
+	reg := NewRegistry(DefaultConfig(), NewMemoryStore())
+	g, err := reg.CreateGame(&Player{ID: "a", Name: "a", IsAdmin: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.store.DeleteEvents(g.ID); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range log {
+		if err := reg.store.AppendEvent(g.ID, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := g.History(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var seqs []int64
+			for _, e := range page.Events {
+				seqs = append(seqs, e.Seq)
+			}
+			if !reflect.DeepEqual(seqs, tt.wantSeqs) {
+				t.Errorf("events = %v, want %v", seqs, tt.wantSeqs)
+			}
+			if page.NextCursor != tt.wantCursor {
+				t.Errorf("nextCursor = %d, want %d", page.NextCursor, tt.wantCursor)
+			}
+		})
+	}
+}
+
+func TestHistoryLimitIsCapped(t *testing.T) {
+	reg := NewRegistry(DefaultConfig(), NewMemoryStore())
+	g, err := reg.CreateGame(&Player{ID: "a", Name: "a", IsAdmin: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.store.DeleteEvents(g.ID); err != nil {
+		t.Fatal(err)
+	}
+	for seq := int64(1); seq <= maxHistoryLimit+1; seq++ {
+		if err := reg.store.AppendEvent(g.ID, Event{Seq: seq, Type: EventAction}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, limit := range []int{0, maxHistoryLimit + 1} {
+		page, err := g.History(HistoryQuery{Limit: limit})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := defaultHistoryLimit
+		if limit > 0 {
+			want = maxHistoryLimit
+		}
+		if len(page.Events) != want {
+			t.Errorf("limit %d returned %d events, want %d", limit, len(page.Events), want)
+		}
+	}
+}
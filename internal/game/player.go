@@ -6,4 +6,7 @@ type Player struct {
 	Seat     int
 	IsAdmin  bool
 	Hand     []*Card
+	// Token proves a connection holds this seat; it is only ever sent to
+	// the player it was issued to.
+	Token    string
 }
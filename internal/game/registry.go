@@ -0,0 +1,275 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Config holds the tunables a Registry applies to its games.
+type Config struct {
+	Codes CodeFormat
+	// HandSize is how many cards each player is dealt at the start of a
+	// round.
+	HandSize int
+	// RecentEvents is how many events players see on the table; the full
+	// log stays in the store.
+	RecentEvents int
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Codes:        DefaultCodeFormat(),
+		HandSize:     7,
+		RecentEvents: 10,
+	}
+}
+
+func (c Config) Validate() error {
+	if err := c.Codes.Validate(); err != nil {
+		return err
+	}
+	if c.HandSize < 1 || c.HandSize > 52 {
+		return errors.New("hand size must be between 1 and 52")
+	}
+	if c.RecentEvents < 1 {
+		return errors.New("recent events must be at least 1")
+	}
+	return nil
+}
+
+// ErrShuttingDown is returned for new games once the registry is closed.
+var ErrShuttingDown = errors.New("server is shutting down")
+
+// Registry holds the live games, keyed by their code.
+type Registry struct {
+	config Config
+	store  Store
+
+	mu     sync.RWMutex
+	games  map[string]*Game
+	closed bool
+}
+
+func NewRegistry(config Config, store Store) *Registry {
+	return &Registry{
+		config: config,
+		store:  store,
+		games:  make(map[string]*Game),
+	}
+}
+
+func (reg *Registry) CreateGame(adminPlayer *Player) (*Game, error) {
+	if adminPlayer == nil {
+		return nil, errors.New("admin player cannot be nil")
+	}
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if reg.closed {
+		return nil, ErrShuttingDown
+	}
+
+	gameID, err := reg.allocateCode()
+	if err != nil {
+		return nil, err
+	}
+	adminPlayer.Token = newSeatToken()
+
+	game := &Game{
+		ID:       gameID,
+		Status:   GameWaiting,
+		Players:  []*Player{adminPlayer},
+		AdminID:  adminPlayer.ID,
+		Settings: DefaultSettings(),
+		Seed:     time.Now().UnixNano(),
+		registry: reg,
+	}
+	game.touch()
+
+	reg.games[gameID] = game
+
+	return game, nil
+}
+
+// JoinGame seats a player in a game that has not started. The caller holds
+// the game's lock.
+func (reg *Registry) JoinGame(gameID string, player *Player) (*Game, error) {
+	if player == nil {
+		return nil, errors.New("player cannot be nil")
+	}
+
+	game, err := reg.GetGame(gameID)
+	if err != nil {
+		return nil, err
+	}
+
+	if game.Status != GameWaiting {
+		return nil, errors.New("game already started")
+	}
+
+	for _, p := range game.Players {
+		if p.ID == player.ID {
+			return nil, errors.New("player already in game")
+		}
+	}
+
+	player.Token = newSeatToken()
+	game.Players = append(game.Players, player)
+	game.touch()
+
+	return game, nil
+}
+
+// RejoinGame lets a player who is already seated reattach to a game, e.g.
+// after a reconnect or once an imported game is resumed. The seat token
+// issued when they joined proves the seat is theirs. The caller holds the
+// game's lock.
+func (reg *Registry) RejoinGame(gameID, playerID, token string) (*Game, error) {
+	game, err := reg.GetGame(gameID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := game.Authenticate(playerID, token); err != nil {
+		return nil, err
+	}
+	game.touch()
+
+	return game, nil
+}
+
+// liveGames lists the games held at the time of the call, so each can be
+// locked in turn without holding the registry lock: a game's lock is always
+// taken before the registry's.
+func (reg *Registry) liveGames() []*Game {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	games := make([]*Game, 0, len(reg.games))
+	for _, g := range reg.games {
+		games = append(games, g)
+	}
+	return games
+}
+
+func (reg *Registry) GetGame(gameID string) (*Game, error) {
+	reg.mu.RLock()
+	game, ok := reg.games[gameID]
+	reg.mu.RUnlock()
+	if !ok {
+		return nil, errors.New("game not found")
+	}
+	return game, nil
+}
+
+// Close stops the registry from taking new games; existing games carry on
+// until they are flushed.
+func (reg *Registry) Close() {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	reg.closed = true
+}
+
+// Flush saves every game to the store as a snapshot, for Restore to pick up
+// on the next start.
+func (reg *Registry) Flush() error {
+	games := reg.liveGames()
+
+	var failed int
+	for _, g := range games {
+		g.Lock()
+		s, err := g.Snapshot()
+		g.Unlock()
+		if err == nil {
+			err = reg.store.SaveSnapshot(s)
+		}
+		if err != nil {
+			log.Printf("game %s: cannot save snapshot: %v", g.ID, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d games not saved", failed, len(games))
+	}
+	return nil
+}
+
+// Restore brings back the games saved by Flush. A snapshot is removed once
+// its game is live again, so it is not restored twice; one that cannot be
+// restored is left in the store and logged. Codes of games lost without a
+// snapshot, e.g. in a crash, are then released along with their events.
+func (reg *Registry) Restore() (int, error) {
+	snapshots, err := reg.store.Snapshots()
+	if err != nil {
+		return 0, err
+	}
+
+	restored := 0
+	kept := make(map[string]bool)
+	for _, s := range snapshots {
+		if _, err := reg.importSnapshot(s, importRestore); err != nil {
+			log.Printf("game %s: cannot restore: %v", s.Game.ID, err)
+			kept[s.Game.ID] = true
+			continue
+		}
+		if err := reg.store.DeleteSnapshot(s.Game.ID); err != nil {
+			log.Printf("game %s: cannot delete snapshot: %v", s.Game.ID, err)
+		}
+		restored++
+	}
+
+	if err := reg.releaseOrphanedCodes(kept); err != nil {
+		return restored, err
+	}
+	return restored, nil
+}
+
+// releaseOrphanedCodes frees every reserved code that belongs to no live
+// game, no retained history and none of the kept snapshots. Games that were
+// live when the process died hold such codes.
+func (reg *Registry) releaseOrphanedCodes(kept map[string]bool) error {
+	codes, err := reg.store.Codes()
+	if err != nil {
+		return err
+	}
+	retained, err := reg.store.RetainedHistories()
+	if err != nil {
+		return err
+	}
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	for _, code := range codes {
+		if reg.games[code] != nil || kept[code] {
+			continue
+		}
+		if _, ok := retained[code]; ok {
+			continue
+		}
+		// events go before the code is free, or a new game could inherit them
+		if err := reg.store.DeleteEvents(code); err != nil {
+			log.Printf("game %s: cannot delete events: %v", code, err)
+			continue
+		}
+		if err := reg.store.ReleaseCode(code); err != nil {
+			log.Printf("game %s: cannot release code: %v", code, err)
+			continue
+		}
+		log.Printf("game %s: released the code of a game lost without a snapshot", code)
+	}
+	return nil
+}
+
+// trimRecentEvents keeps the configured window of events shown to
+// players.
+func (g *Game) trimRecentEvents() {
+	if n := g.registry.config.RecentEvents; len(g.RecentEvents) > n {
+		g.RecentEvents = g.RecentEvents[len(g.RecentEvents)-n:]
+	}
+}
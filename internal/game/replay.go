@@ -0,0 +1,153 @@
+package game
+
+import "errors"
+
+// ReplayFrame is the public table state right after Event was applied.
+// Hands are not recorded in the log, so only their sizes are replayed.
+type ReplayFrame struct {
+	Step       int
+	Event      Event
+	Round      int
+	Status     GameStatus
+	TopCard    *Card
+	HandCounts map[string]int
+	Seating    []string
+	WinnerID   string
+}
+
+type replayResolution struct {
+	deltas      map[string]int
+	prevTopCard *Card
+	prevStatus  GameStatus
+	prevWinner  string
+}
+
+// Replay rebuilds every frame of a game from its stored event log. It works
+// from the store alone, so finished games can be replayed after they end.
+func (reg *Registry) Replay(gameID string) ([]ReplayFrame, error) {
+	events, err := reg.store.Events(gameID)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, errors.New("no recorded events for game")
+	}
+
+	state := ReplayFrame{
+		Status:     GameWaiting,
+		HandCounts: make(map[string]int),
+	}
+	resolutions := make(map[string]*replayResolution)
+
+	resolutionFor := func(actionID string) *replayResolution {
+		r, ok := resolutions[actionID]
+		if !ok {
+			r = &replayResolution{
+				deltas:      make(map[string]int),
+				prevTopCard: state.TopCard,
+				prevStatus:  state.Status,
+				prevWinner:  state.WinnerID,
+			}
+			resolutions[actionID] = r
+		}
+		return r
+	}
+
+	adjust := func(e Event, delta int) {
+		state.HandCounts[e.PlayerID] += delta
+		if e.ActionID != "" {
+			resolutionFor(e.ActionID).deltas[e.PlayerID] += delta
+		}
+	}
+
+	frames := make([]ReplayFrame, 0, len(events))
+	for i, e := range events {
+		switch e.Type {
+		case EventAction:
+			switch e.ActionType {
+			case "START_GAME", "START_ROUND":
+				if e.ActionType == "START_GAME" {
+					state.Round = 1
+				} else {
+					state.Round++
+				}
+				state.Status = GameActive
+				state.WinnerID = ""
+				state.TopCard = e.Card
+				state.Seating = e.Players
+				state.HandCounts = make(map[string]int)
+				for _, id := range e.Players {
+					state.HandCounts[id] = e.Dealt
+				}
+			case string(ActionPlayCard):
+				if e.Resolution == string(ResolutionReject) {
+					break
+				}
+				resolutionFor(e.ActionID)
+				adjust(e, -1)
+				state.TopCard = e.Card
+			case string(ActionDraw):
+				if e.Resolution == string(ResolutionReject) {
+					break
+				}
+				adjust(e, 1)
+			}
+		case EventPenalty:
+			adjust(e, e.Penalty)
+		case EventDiscard:
+			adjust(e, -e.Discarded)
+		case EventKick:
+			delete(state.HandCounts, e.PlayerID)
+			seating := make([]string, 0, len(state.Seating))
+			for _, id := range state.Seating {
+				if id != e.PlayerID {
+					seating = append(seating, id)
+				}
+			}
+			state.Seating = seating
+		case EventRoundWon:
+			if e.ActionID != "" {
+				resolutionFor(e.ActionID)
+			}
+			state.Status = GameEnded
+			state.WinnerID = e.PlayerID
+		case EventSessionEnded:
+			state.Status = GameEnded
+		case EventAppealClosed:
+			r, ok := resolutions[e.ActionID]
+			if !e.Overturn || !ok {
+				break
+			}
+			for id, d := range r.deltas {
+				if _, seated := state.HandCounts[id]; seated {
+					state.HandCounts[id] -= d
+				}
+			}
+			state.TopCard = r.prevTopCard
+			state.Status = r.prevStatus
+			state.WinnerID = r.prevWinner
+		}
+
+		frame := state
+		frame.Step = i + 1
+		frame.Event = e
+		frame.HandCounts = make(map[string]int, len(state.HandCounts))
+		for id, n := range state.HandCounts {
+			frame.HandCounts[id] = n
+		}
+		frames = append(frames, frame)
+	}
+	return frames, nil
+}
+
+// ReplayStep returns the frame after the first step events were applied.
+func (reg *Registry) ReplayStep(gameID string, step int) (ReplayFrame, error) {
+	frames, err := reg.Replay(gameID)
+	if err != nil {
+		return ReplayFrame{}, err
+	}
+	if step < 1 || step > len(frames) {
+		return ReplayFrame{}, errors.New("step out of range")
+	}
+	return frames[step-1], nil
+}
@@ -0,0 +1,223 @@
+package game
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// script drives a live game; its replay must end where the game did.
+type script struct {
+	g    *Game
+	t    *testing.T
+	next int
+}
+
+func (s *script) action(playerID string, typ ActionType) *Action {
+	s.next++
+	a := &Action{ID: fmt.Sprintf("act-%d", s.next), PlayerID: playerID, Type: typ}
+	if typ == ActionPlayCard {
+		a.Card = mustPlayer(s.t, s.g, playerID).Hand[0]
+	}
+	return a
+}
+
+func (s *script) play(playerID string, resolution ActionResolution, penalty int, challengers ...string) {
+	s.t.Helper()
+	play(s.t, s.g, s.action(playerID, ActionPlayCard), resolution, penalty, challengers...)
+}
+
+func (s *script) draw(playerID string, resolution ActionResolution) {
+	s.t.Helper()
+	play(s.t, s.g, s.action(playerID, ActionDraw), resolution, 0)
+}
+
+func (s *script) overturn() {
+	s.t.Helper()
+	if err := s.g.CallAppeal("b"); err != nil {
+		s.t.Fatal(err)
+	}
+	if err := s.g.VoteAppeal("c", true); err != nil {
+		s.t.Fatal(err)
+	}
+}
+
+func (s *script) must(err error) {
+	s.t.Helper()
+	if err != nil {
+		s.t.Fatal(err)
+	}
+}
+
+func TestReplayMatchesLiveGame(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy ChallengePolicy
+		run    func(s *script)
+	}{
+		{
+			name: "accepted play",
+			run:  func(s *script) { s.play("b", ResolutionAccept, 0) },
+		},
+		{
+			name: "rejected play with penalty",
+			run:  func(s *script) { s.play("b", ResolutionReject, 2, "c") },
+		},
+		{
+			name: "accepted play with penalty",
+			run:  func(s *script) { s.play("b", ResolutionAcceptWithPenalty, 1) },
+		},
+		{
+			name: "draws",
+			run: func(s *script) {
+				s.draw("b", ResolutionAccept)
+				s.draw("c", ResolutionReject)
+			},
+		},
+		{
+			name: "admin penalty",
+			run:  func(s *script) { s.must(s.g.PenalizePlayer("a", "c", 2)) },
+		},
+		{
+			name:   "challenge policy",
+			policy: ChallengePolicy{WrongChallengerPenalty: 2, WrongAcceptorPenalty: 1, CorrectChallengerDiscard: 1},
+			run: func(s *script) {
+				s.play("b", ResolutionAccept, 0, "c")
+				a := s.action("c", ActionPlayCard)
+				a.AcceptedBy = map[string]bool{}
+				a.ChallengedBy = map[string]bool{}
+				s.must(s.g.ProposeAction(a))
+				s.must(s.g.AcceptAction("a"))
+				s.must(s.g.ChallengeAction("b"))
+				s.must(s.g.ResolveAction("a", ResolutionReject, 1))
+			},
+		},
+		{
+			name: "overturned accepted play",
+			run: func(s *script) {
+				s.play("b", ResolutionAccept, 0)
+				s.overturn()
+			},
+		},
+		{
+			name:   "overturned rejection with discard",
+			policy: ChallengePolicy{CorrectChallengerDiscard: 2},
+			run: func(s *script) {
+				s.play("b", ResolutionReject, 2, "c")
+				s.overturn()
+			},
+		},
+		{
+			name: "overturn keeps earlier penalties",
+			run: func(s *script) {
+				s.must(s.g.PenalizePlayer("a", "b", 1))
+				s.draw("b", ResolutionAccept)
+				s.overturn()
+			},
+		},
+		{
+			name: "kick",
+			run: func(s *script) {
+				s.must(s.g.PenalizePlayer("a", "c", 3))
+				s.must(s.g.KickPlayer("a", "c"))
+				s.play("b", ResolutionAccept, 0)
+			},
+		},
+		{
+			name: "kick the current actor",
+			run: func(s *script) {
+				a := s.action("c", ActionPlayCard)
+				a.AcceptedBy = map[string]bool{}
+				a.ChallengedBy = map[string]bool{}
+				s.must(s.g.ProposeAction(a))
+				s.must(s.g.KickPlayer("a", "c"))
+			},
+		},
+		{
+			name: "round won",
+			run: func(s *script) {
+				for i := 0; i < 3; i++ {
+					s.play("b", ResolutionAccept, 0)
+				}
+			},
+		},
+		{
+			name: "overturned winning play",
+			run: func(s *script) {
+				for i := 0; i < 3; i++ {
+					s.play("b", ResolutionAccept, 0)
+				}
+				s.overturn()
+			},
+		},
+		{
+			name: "new round",
+			run: func(s *script) {
+				s.must(s.g.PenalizePlayer("a", "c", 2))
+				for i := 0; i < 3; i++ {
+					s.play("b", ResolutionAccept, 0)
+				}
+				s.must(s.g.SubmitWinnerRule("b", "no talking"))
+				s.must(s.g.StartNextRound("a"))
+				s.play("c", ResolutionReject, 1)
+			},
+		},
+		{
+			name: "session ended",
+			run: func(s *script) {
+				for i := 0; i < 3; i++ {
+					s.play("b", ResolutionAccept, 0)
+				}
+				s.must(s.g.EndSession("a"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.HandSize = 3
+			reg := NewRegistry(config, NewMemoryStore())
+			g, err := reg.CreateGame(&Player{ID: "a", Name: "a", IsAdmin: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, id := range []string{"b", "c"} {
+				if _, err := reg.JoinGame(g.ID, &Player{ID: id, Name: id}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := g.StartGame("a"); err != nil {
+				t.Fatal(err)
+			}
+			g.Settings.AppealsEnabled = true
+			g.Settings.ChallengePolicy = tt.policy
+
+			tt.run(&script{g: g, t: t})
+
+			frames, err := reg.Replay(g.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			last := frames[len(frames)-1]
+
+			hands := make(map[string]int)
+			for _, p := range g.Players {
+				hands[p.ID] = len(p.Hand)
+			}
+			if !reflect.DeepEqual(last.HandCounts, hands) {
+				t.Errorf("replayed hand counts %v, live %v", last.HandCounts, hands)
+			}
+			if !reflect.DeepEqual(last.Seating, g.seating()) {
+				t.Errorf("replayed seating %v, live %v", last.Seating, g.seating())
+			}
+			if !reflect.DeepEqual(last.TopCard, g.TopCard) {
+				t.Errorf("replayed top card %v, live %v", last.TopCard, g.TopCard)
+			}
+			if last.Status != g.Status || last.WinnerID != g.WinnerID || last.Round != g.Session.Round {
+				t.Errorf("replayed status %s winner %q round %d, live %s %q %d",
+					last.Status, last.WinnerID, last.Round, g.Status, g.WinnerID, g.Session.Round)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package game
+
+import (
+	"math/rand"
+	"time"
+)
+
+// countingSource wraps a seeded source and counts how many values it has
+// produced, so a game's RNG can be restored to the same position later.
+type countingSource struct {
+	src   rand.Source
+	calls int64
+}
+
+func (s *countingSource) Int63() int64 {
+	s.calls++
+	return s.src.Int63()
+}
+
+func (s *countingSource) Seed(seed int64) {
+	s.src.Seed(seed)
+	s.calls = 0
+}
+
+func newCountingSource(seed int64, calls int64) *countingSource {
+	s := &countingSource{src: rand.NewSource(seed)}
+	for i := int64(0); i < calls; i++ {
+		s.Int63()
+	}
+	return s
+}
+
+func (g *Game) random() *rand.Rand {
+	if g.rng == nil {
+		if g.Seed == 0 {
+			g.Seed = time.Now().UnixNano()
+		}
+		g.rngSource = newCountingSource(g.Seed, 0)
+		g.rng = rand.New(g.rngSource)
+	}
+	return g.rng
+}
+
+func (g *Game) newCard() *Card {
+	r := g.random()
+	return &Card{
+		Rank: ranks[r.Intn(len(ranks))],
+		Suit: suits[r.Intn(len(suits))],
+	}
+}
@@ -0,0 +1,152 @@
+package game
+
+import (
+	"errors"
+	"time"
+)
+
+type Permission string
+
+const (
+	PermResolveActions Permission = "RESOLVE_ACTIONS"
+	PermApplyPenalties Permission = "APPLY_PENALTIES"
+	PermStartGame      Permission = "START_GAME"
+	PermKickPlayers    Permission = "KICK_PLAYERS"
+)
+
+var allPermissions = []Permission{
+	PermResolveActions,
+	PermApplyPenalties,
+	PermStartGame,
+	PermKickPlayers,
+}
+
+func validPermission(perm Permission) bool {
+	for _, p := range allPermissions {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPermission reports whether a player may perform a privileged
+// operation. The admin implicitly holds every permission.
+func (g *Game) HasPermission(playerID string, perm Permission) bool {
+	if playerID == "" {
+		return false
+	}
+	if playerID == g.AdminID {
+		return true
+	}
+	return g.Judges[playerID][perm]
+}
+
+// Permissions lists what a player has been granted, in a stable order.
+func (g *Game) Permissions(playerID string) []Permission {
+	var perms []Permission
+	for _, perm := range allPermissions {
+		if g.HasPermission(playerID, perm) {
+			perms = append(perms, perm)
+		}
+	}
+	return perms
+}
+
+func (g *Game) IsJudge(playerID string) bool {
+	return len(g.Permissions(playerID)) > 0
+}
+
+func (g *Game) GrantPermission(adminID, playerID string, perm Permission) error {
+	if g.AdminID != adminID {
+		return errors.New("only admin can grant permissions")
+	}
+
+	if !validPermission(perm) {
+		return errors.New("unknown permission")
+	}
+
+	if _, err := g.findPlayer(playerID); err != nil {
+		return err
+	}
+
+	if playerID == g.AdminID {
+		return errors.New("admin already has every permission")
+	}
+
+	if g.Judges == nil {
+		g.Judges = make(map[string]map[Permission]bool)
+	}
+	if g.Judges[playerID] == nil {
+		g.Judges[playerID] = make(map[Permission]bool)
+	}
+	g.Judges[playerID][perm] = true
+	return nil
+}
+
+func (g *Game) RevokePermission(adminID, playerID string, perm Permission) error {
+	if g.AdminID != adminID {
+		return errors.New("only admin can revoke permissions")
+	}
+
+	if !validPermission(perm) {
+		return errors.New("unknown permission")
+	}
+
+	delete(g.Judges[playerID], perm)
+	if len(g.Judges[playerID]) == 0 {
+		delete(g.Judges, playerID)
+	}
+	return nil
+}
+
+func (g *Game) PenalizePlayer(actorID, playerID string, count int) error {
+	if !g.HasPermission(actorID, PermApplyPenalties) {
+		return errors.New("not allowed to apply penalties")
+	}
+
+	return g.ApplyPenalty(playerID, count)
+}
+
+func (g *Game) KickPlayer(actorID, playerID string) error {
+	if !g.HasPermission(actorID, PermKickPlayers) {
+		return errors.New("not allowed to kick players")
+	}
+
+	if playerID == g.AdminID {
+		return errors.New("cannot kick the admin")
+	}
+
+	if _, err := g.findPlayer(playerID); err != nil {
+		return err
+	}
+
+	for i, p := range g.Players {
+		if p.ID == playerID {
+			g.Players = append(g.Players[:i], g.Players[i+1:]...)
+			break
+		}
+	}
+	delete(g.Judges, playerID)
+
+	if g.CurrentAction != nil {
+		if g.CurrentAction.PlayerID == playerID {
+			g.ClearAction()
+		} else {
+			delete(g.CurrentAction.AcceptedBy, playerID)
+			delete(g.CurrentAction.ChallengedBy, playerID)
+		}
+	}
+
+	if g.Appeal != nil {
+		delete(g.Appeal.Votes, playerID)
+		g.tallyAppeal()
+	}
+
+	g.pushEvent(Event{
+		Type:      EventKick,
+		PlayerID:  playerID,
+		Timestamp: time.Now().Unix(),
+	})
+	return nil
+}
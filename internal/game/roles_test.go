@@ -0,0 +1,214 @@
+package game
+
+import (
+	"reflect"
+	"testing"
+)
+
+// propose puts an action by playerID up for judgement.
+func propose(t *testing.T, g *Game, playerID string) *Action {
+	t.Helper()
+
+	a := &Action{
+		ID:           "act",
+		PlayerID:     playerID,
+		Type:         ActionPlayCard,
+		Card:         mustPlayer(t, g, playerID).Hand[0],
+		AcceptedBy:   map[string]bool{},
+		ChallengedBy: map[string]bool{},
+	}
+	if err := g.ProposeAction(a); err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestGrantAndRevokePermission(t *testing.T) {
+	_, g := newTestGame(t, "a", "b", "c")
+
+	steps := []struct {
+		name   string
+		apply  func() error
+		wantB  []Permission
+		wantOK bool
+	}{
+		{name: "grant resolve", apply: func() error { return g.GrantPermission("a", "b", PermResolveActions) }, wantB: []Permission{PermResolveActions}, wantOK: true},
+		{name: "grant kick", apply: func() error { return g.GrantPermission("a", "b", PermKickPlayers) }, wantB: []Permission{PermResolveActions, PermKickPlayers}, wantOK: true},
+		{name: "grant twice", apply: func() error { return g.GrantPermission("a", "b", PermKickPlayers) }, wantB: []Permission{PermResolveActions, PermKickPlayers}, wantOK: true},
+		{name: "grant unknown permission", apply: func() error { return g.GrantPermission("a", "b", "FLY") }, wantB: []Permission{PermResolveActions, PermKickPlayers}},
+		{name: "grant to a stranger", apply: func() error { return g.GrantPermission("a", "zed", PermKickPlayers) }, wantB: []Permission{PermResolveActions, PermKickPlayers}},
+		{name: "grant to the admin", apply: func() error { return g.GrantPermission("a", "a", PermKickPlayers) }, wantB: []Permission{PermResolveActions, PermKickPlayers}},
+		{name: "revoke resolve", apply: func() error { return g.RevokePermission("a", "b", PermResolveActions) }, wantB: []Permission{PermKickPlayers}, wantOK: true},
+		{name: "revoke unknown permission", apply: func() error { return g.RevokePermission("a", "b", "FLY") }, wantB: []Permission{PermKickPlayers}},
+		{name: "revoke kick", apply: func() error { return g.RevokePermission("a", "b", PermKickPlayers) }, wantOK: true},
+		{name: "revoke from a non-judge", apply: func() error { return g.RevokePermission("a", "b", PermKickPlayers) }, wantOK: true},
+	}
+
+	for _, step := range steps {
+		err := step.apply()
+		if (err == nil) != step.wantOK {
+			t.Errorf("%s: got error %v, want success %v", step.name, err, step.wantOK)
+		}
+		if got := g.Permissions("b"); !reflect.DeepEqual(got, step.wantB) {
+			t.Errorf("%s: b holds %v, want %v", step.name, got, step.wantB)
+		}
+		if g.IsJudge("b") != (len(step.wantB) > 0) {
+			t.Errorf("%s: IsJudge(b) = %v", step.name, g.IsJudge("b"))
+		}
+	}
+
+	if _, ok := g.Judges["b"]; ok {
+		t.Error("b still listed as a judge with no permissions")
+	}
+	if got := g.Permissions("a"); !reflect.DeepEqual(got, allPermissions) {
+		t.Errorf("admin holds %v, want every permission", got)
+	}
+}
+
+func TestNonAdminRefused(t *testing.T) {
+	_, g := newTestGame(t, "a", "b", "c", "d")
+	// b is a judge with every permission, c has none
+	for _, perm := range allPermissions {
+		if err := g.GrantPermission("a", "b", perm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	propose(t, g, "d")
+
+	tests := []struct {
+		name  string
+		apply func() error
+	}{
+		{name: "judge grants", apply: func() error { return g.GrantPermission("b", "c", PermKickPlayers) }},
+		{name: "judge revokes", apply: func() error { return g.RevokePermission("b", "b", PermKickPlayers) }},
+		{name: "player grants", apply: func() error { return g.GrantPermission("c", "c", PermKickPlayers) }},
+		{name: "player revokes", apply: func() error { return g.RevokePermission("c", "b", PermKickPlayers) }},
+		{name: "player resolves", apply: func() error { return g.ResolveAction("c", ResolutionReject, 1) }},
+		{name: "player penalizes", apply: func() error { return g.PenalizePlayer("c", "d", 1) }},
+		{name: "player kicks", apply: func() error { return g.KickPlayer("c", "d") }},
+		{name: "nobody resolves", apply: func() error { return g.ResolveAction("", ResolutionReject, 1) }},
+		{name: "judge kicks the admin", apply: func() error { return g.KickPlayer("b", "a") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.apply(); err == nil {
+				t.Fatal("refused operation succeeded")
+			}
+		})
+	}
+
+	if g.IsJudge("c") || len(g.Permissions("b")) != len(allPermissions) {
+		t.Errorf("permissions changed: b %v, c %v", g.Permissions("b"), g.Permissions("c"))
+	}
+	if len(g.Players) != 4 || g.CurrentAction == nil || len(mustPlayer(t, g, "d").Hand) != 7 {
+		t.Errorf("game changed: %d players, current action %+v", len(g.Players), g.CurrentAction)
+	}
+
+	// starting needs its own permission too
+	reg := NewRegistry(DefaultConfig(), NewMemoryStore())
+	waiting, err := reg.CreateGame(&Player{ID: "a", Name: "a", IsAdmin: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reg.JoinGame(waiting.ID, &Player{ID: "b", Name: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := waiting.StartGame("b"); err == nil || waiting.Status != GameWaiting {
+		t.Errorf("player started the game: %v, status %s", err, waiting.Status)
+	}
+	if err := waiting.GrantPermission("a", "b", PermStartGame); err != nil {
+		t.Fatal(err)
+	}
+	if err := waiting.StartGame("b"); err != nil {
+		t.Errorf("judge could not start the game: %v", err)
+	}
+}
+
+func TestKickPlayer(t *testing.T) {
+	t.Run("current actor", func(t *testing.T) {
+		_, g := newTestGame(t, "a", "b", "c")
+		propose(t, g, "c")
+		if err := g.AcceptAction("b"); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := g.KickPlayer("a", "c"); err != nil {
+			t.Fatal(err)
+		}
+		if g.CurrentAction != nil {
+			t.Errorf("kicked player's action still pending: %+v", g.CurrentAction)
+		}
+		if _, err := g.findPlayer("c"); err == nil {
+			t.Error("kicked player still seated")
+		}
+		// the table can go on without them
+		propose(t, g, "b")
+	})
+
+	t.Run("bystander", func(t *testing.T) {
+		_, g := newTestGame(t, "a", "b", "c", "d")
+		a := propose(t, g, "d")
+		if err := g.ChallengeAction("c"); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := g.KickPlayer("a", "c"); err != nil {
+			t.Fatal(err)
+		}
+		if g.CurrentAction != a || a.ChallengedBy["c"] {
+			t.Errorf("current action %+v, want d's action without c's challenge", g.CurrentAction)
+		}
+	})
+
+	t.Run("judge kicks and is kicked", func(t *testing.T) {
+		_, g := newTestGame(t, "a", "b", "c")
+		if err := g.GrantPermission("a", "b", PermKickPlayers); err != nil {
+			t.Fatal(err)
+		}
+		if err := g.KickPlayer("b", "c"); err != nil {
+			t.Fatalf("judge could not kick: %v", err)
+		}
+		if err := g.KickPlayer("a", "b"); err != nil {
+			t.Fatal(err)
+		}
+		if g.IsJudge("b") {
+			t.Error("kicked judge kept their permissions")
+		}
+		if err := g.KickPlayer("a", "b"); err == nil {
+			t.Error("kicked a player who is not seated")
+		}
+	})
+}
+
+func TestJudgeResolvesWithoutAdminRights(t *testing.T) {
+	_, g := newTestGame(t, "a", "b", "c", "d")
+	if err := g.GrantPermission("a", "b", PermResolveActions); err != nil {
+		t.Fatal(err)
+	}
+
+	a := propose(t, g, "d")
+	if err := g.ChallengeAction("c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.ResolveAction("b", ResolutionReject, 2); err != nil {
+		t.Fatalf("judge could not resolve: %v", err)
+	}
+	if !a.Resolved || a.ResolvedBy != "b" || g.LastRejectedAction != a {
+		t.Errorf("action %+v not rejected by b", a)
+	}
+	if got := len(mustPlayer(t, g, "d").Hand); got != 9 {
+		t.Errorf("proposer holds %d cards, want 9", got)
+	}
+
+	// resolving grants nothing else
+	if err := g.PenalizePlayer("b", "d", 1); err == nil {
+		t.Error("judge penalized without the permission")
+	}
+	if err := g.KickPlayer("b", "d"); err == nil {
+		t.Error("judge kicked without the permission")
+	}
+	if err := g.GrantPermission("b", "c", PermResolveActions); err == nil {
+		t.Error("judge granted a permission")
+	}
+}
@@ -0,0 +1,90 @@
+package game
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Rule struct {
+	ID        string
+	Text      string
+	CreatedAt time.Time
+	AddedBy   string
+}
+
+// CanViewRulebook reports whether the private rulebook may be shown to a
+// player. Judges always see it; everyone else only once the session has
+// ended, not merely a round, and the admin chose to reveal it.
+func (g *Game) CanViewRulebook(playerID string) bool {
+	if g.IsJudge(playerID) {
+		return true
+	}
+	return g.Session.Ended && g.Settings.RevealRulebookOnEnd
+}
+
+func (g *Game) Rules(playerID string) ([]*Rule, error) {
+	if !g.CanViewRulebook(playerID) {
+		return nil, errors.New("rulebook is private")
+	}
+	return g.Rulebook, nil
+}
+
+func (g *Game) AddRule(actorID, text string) (*Rule, error) {
+	if !g.IsJudge(actorID) {
+		return nil, errors.New("only judges can edit the rulebook")
+	}
+
+	return g.addRule(actorID, text)
+}
+
+func (g *Game) addRule(authorID, text string) (*Rule, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, errors.New("rule text cannot be empty")
+	}
+
+	g.ruleSeq++
+	rule := &Rule{
+		ID:        strconv.Itoa(g.ruleSeq),
+		Text:      text,
+		CreatedAt: time.Now(),
+		AddedBy:   authorID,
+	}
+	g.Rulebook = append(g.Rulebook, rule)
+	return rule, nil
+}
+
+func (g *Game) EditRule(actorID, ruleID, text string) error {
+	if !g.IsJudge(actorID) {
+		return errors.New("only judges can edit the rulebook")
+	}
+
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return errors.New("rule text cannot be empty")
+	}
+
+	for _, r := range g.Rulebook {
+		if r.ID == ruleID {
+			r.Text = text
+			return nil
+		}
+	}
+	return errors.New("rule not found")
+}
+
+func (g *Game) RemoveRule(actorID, ruleID string) error {
+	if !g.IsJudge(actorID) {
+		return errors.New("only judges can edit the rulebook")
+	}
+
+	for i, r := range g.Rulebook {
+		if r.ID == ruleID {
+			g.Rulebook = append(g.Rulebook[:i], g.Rulebook[i+1:]...)
+			return nil
+		}
+	}
+	return errors.New("rule not found")
+}
@@ -0,0 +1,48 @@
+package game
+
+import "testing"
+
+func TestCanViewRulebook(t *testing.T) {
+	tests := []struct {
+		name   string
+		player string
+		reveal bool
+		stage  func(t *testing.T, g *Game)
+		want   bool
+	}{
+		{name: "judge during a round", player: "a", want: true},
+		{name: "player during a round", player: "b", reveal: true},
+		{name: "player between rounds", player: "b", reveal: true, stage: winRound},
+		{name: "player after session without reveal", player: "b", stage: endSession},
+		{name: "player after session with reveal", player: "b", reveal: true, stage: endSession, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, g := newTestGame(t, "a", "b")
+			g.Settings.RevealRulebookOnEnd = tt.reveal
+			if tt.stage != nil {
+				tt.stage(t, g)
+			}
+
+			if got := g.CanViewRulebook(tt.player); got != tt.want {
+				t.Errorf("CanViewRulebook(%q) = %v, want %v", tt.player, got, tt.want)
+			}
+		})
+	}
+}
+
+func winRound(t *testing.T, g *Game) {
+	p := mustPlayer(t, g, "b")
+	p.Hand = p.Hand[:1]
+	play(t, g, &Action{ID: "win", PlayerID: "b", Type: ActionPlayCard, Card: p.Hand[0]}, ResolutionAccept, 0)
+	if g.Status != GameEnded {
+		t.Fatal("round did not end")
+	}
+}
+
+func endSession(t *testing.T, g *Game) {
+	if err := g.EndSession(g.AdminID); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,135 @@
+package game
+
+import (
+	"errors"
+	"time"
+)
+
+type RoundResult struct {
+	Round    int
+	WinnerID string
+	EndedAt  time.Time
+}
+
+// Session tracks the rounds played under one game code. The winner of each
+// round owes the rulebook a new rule before the next round can begin.
+type Session struct {
+	Round           int
+	Results         []RoundResult
+	PendingRuleFrom string
+	Standings       map[string]Standing
+	Ended           bool
+}
+
+func (g *Game) endRound(winnerID string) {
+	results := make([]RoundResult, len(g.Session.Results), len(g.Session.Results)+1)
+	copy(results, g.Session.Results)
+	g.Session.Results = append(results, RoundResult{
+		Round:    g.Session.Round,
+		WinnerID: winnerID,
+		EndedAt:  time.Now(),
+	})
+	g.Session.PendingRuleFrom = winnerID
+	g.pushEvent(Event{
+		Type:      EventRoundWon,
+		PlayerID:  winnerID,
+		Timestamp: time.Now().Unix(),
+	})
+}
+
+func (g *Game) SubmitWinnerRule(playerID, text string) error {
+	if g.Status != GameEnded {
+		return errors.New("round still in progress")
+	}
+
+	if g.Session.PendingRuleFrom != playerID {
+		return errors.New("only the round winner can submit a rule")
+	}
+
+	if _, err := g.addRule(playerID, text); err != nil {
+		return err
+	}
+
+	g.Session.PendingRuleFrom = ""
+	g.pushEvent(Event{
+		Type:      EventRuleSubmitted,
+		PlayerID:  playerID,
+		Timestamp: time.Now().Unix(),
+	})
+	return nil
+}
+
+func (g *Game) StartNextRound(actorID string) error {
+	if g.Status != GameEnded {
+		return errors.New("round still in progress")
+	}
+
+	if !g.HasPermission(actorID, PermStartGame) {
+		return errors.New("not allowed to start next round")
+	}
+
+	if g.Session.Ended {
+		return errors.New("session has ended")
+	}
+
+	if pending := g.Session.PendingRuleFrom; pending != "" {
+		if _, err := g.findPlayer(pending); err == nil {
+			return errors.New("waiting for the winner's new rule")
+		}
+		g.Session.PendingRuleFrom = ""
+	}
+
+	for _, p := range g.Players {
+		p.Hand = nil
+	}
+	g.CurrentAction = nil
+	g.LastSuccessfulAction = nil
+	g.LastRejectedAction = nil
+	g.WinnerID = ""
+	g.Appeal = nil
+	g.lastResolution = nil
+
+	g.Status = GameActive
+	g.Session.Round++
+
+	g.TopCard = g.newCard()
+	g.dealInitialHands()
+
+	g.pushEvent(Event{
+		Type:       EventAction,
+		ActionType: "START_ROUND",
+		Card:       g.TopCard,
+		Players:    g.seating(),
+		Dealt:      g.handSize(),
+		Timestamp:  time.Now().Unix(),
+	})
+
+	return nil
+}
+
+func (g *Game) EndSession(actorID string) error {
+	if g.AdminID != actorID {
+		return errors.New("only admin can end the session")
+	}
+
+	if g.Status == GameWaiting {
+		return errors.New("game not started")
+	}
+
+	if g.Session.Ended {
+		return errors.New("session already ended")
+	}
+
+	g.Status = GameEnded
+	g.CurrentAction = nil
+	g.Appeal = nil
+	g.lastResolution = nil
+	g.Session.PendingRuleFrom = ""
+	g.Session.Ended = true
+	g.pushEvent(Event{
+		Type:      EventSessionEnded,
+		PlayerID:  actorID,
+		Timestamp: time.Now().Unix(),
+	})
+	return nil
+}
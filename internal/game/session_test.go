@@ -0,0 +1,28 @@
+package game
+
+import "testing"
+
+func TestStartNextRoundClearsLastActions(t *testing.T) {
+	_, g := newTestGame(t, "a", "b", "c")
+
+	c := mustPlayer(t, g, "c")
+	play(t, g, &Action{ID: "rejected", PlayerID: "c", Type: ActionPlayCard, Card: c.Hand[0]}, ResolutionReject, 0)
+	winRound(t, g)
+	if g.LastRejectedAction == nil || g.LastSuccessfulAction == nil {
+		t.Fatal("last actions not recorded")
+	}
+
+	if err := g.SubmitWinnerRule("b", "say thank you"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.StartNextRound("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if g.LastRejectedAction != nil {
+		t.Errorf("last rejected action = %+v, want none", g.LastRejectedAction)
+	}
+	if g.LastSuccessfulAction != nil {
+		t.Errorf("last successful action = %+v, want none", g.LastSuccessfulAction)
+	}
+}
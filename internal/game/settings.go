@@ -0,0 +1,74 @@
+package game
+
+import (
+	"errors"
+	"time"
+)
+
+type Settings struct {
+	AppealsEnabled bool
+	AppealWindow   time.Duration
+	// AppealMajority is the fraction of eligible voters that must be
+	// exceeded for an appeal to overturn a resolution.
+	AppealMajority float64
+
+	RevealRulebookOnEnd bool
+
+	ChallengePolicy ChallengePolicy
+}
+
+// ChallengePolicy decides what happens to the players who took a side on an
+// action once it is resolved. Accepting proves challengers wrong; rejecting
+// proves acceptors wrong and challengers right. Accepting with a penalty
+// proves neither side wrong, so the policy leaves both alone.
+type ChallengePolicy struct {
+	WrongChallengerPenalty   int
+	WrongAcceptorPenalty     int
+	CorrectChallengerDiscard int
+}
+
+func DefaultSettings() Settings {
+	return Settings{
+		AppealsEnabled: false,
+		AppealWindow:   30 * time.Second,
+		AppealMajority: 0.5,
+		ChallengePolicy: ChallengePolicy{
+			WrongChallengerPenalty: 1,
+		},
+	}
+}
+
+func (s Settings) Validate() error {
+	if s.AppealWindow <= 0 {
+		return errors.New("appeal window must be positive")
+	}
+	if s.AppealMajority <= 0 || s.AppealMajority >= 1 {
+		return errors.New("appeal majority must be between 0 and 1")
+	}
+	p := s.ChallengePolicy
+	if p.WrongChallengerPenalty < 0 || p.WrongAcceptorPenalty < 0 || p.CorrectChallengerDiscard < 0 {
+		return errors.New("challenge policy counts cannot be negative")
+	}
+	return nil
+}
+
+func (g *Game) UpdateSettings(adminID string, s Settings) error {
+	if g.AdminID != adminID {
+		return errors.New("only admin can change settings")
+	}
+
+	if g.Status == GameEnded {
+		return errors.New("game has ended")
+	}
+
+	if err := s.Validate(); err != nil {
+		return err
+	}
+
+	g.Settings = s
+	if !s.AppealsEnabled {
+		g.Appeal = nil
+		g.lastResolution = nil
+	}
+	return nil
+}
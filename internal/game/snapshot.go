@@ -0,0 +1,582 @@
+package game
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+// maxRNGCalls bounds how far an imported RNG is wound forward: two values per
+// card, for far more cards than any session deals.
+const maxRNGCalls = 1 << 22
+
+// SnapshotVersion is bumped whenever the snapshot layout changes in a way
+// older servers cannot read.
+const SnapshotVersion = 1
+
+// Snapshot is a portable copy of a game that can be downloaded and later
+// imported on any server. An appeal that is still open is not carried over.
+type Snapshot struct {
+	Version    int             `json:"version"`
+	ExportedAt int64           `json:"exportedAt"`
+	Game       SnapshotGame    `json:"game"`
+	Events     []SnapshotEvent `json:"events"`
+	RNG        SnapshotRNG     `json:"rng"`
+	// Checksum is the hex SHA-256 of the snapshot with an empty checksum. It
+	// catches files damaged or edited since download; it is not a signature.
+	Checksum string `json:"checksum,omitempty"`
+}
+
+type SnapshotGame struct {
+	ID                   string                  `json:"id"`
+	Status               GameStatus              `json:"status"`
+	AdminID              string                  `json:"adminId"`
+	Players              []SnapshotPlayer        `json:"players"`
+	Judges               map[string][]Permission `json:"judges,omitempty"`
+	CurrentAction        *SnapshotAction         `json:"currentAction,omitempty"`
+	TopCard              *Card                   `json:"topCard,omitempty"`
+	WinnerID             string                  `json:"winnerId,omitempty"`
+	LastSuccessfulAction *SnapshotAction         `json:"lastSuccessfulAction,omitempty"`
+	LastRejectedAction   *SnapshotAction         `json:"lastRejectedAction,omitempty"`
+	Settings             SnapshotSettings        `json:"settings"`
+	Rulebook             []SnapshotRule          `json:"rulebook,omitempty"`
+	Session              SnapshotSession         `json:"session"`
+}
+
+type SnapshotPlayer struct {
+	ID    string `json:"id"`
+	Name  string `json:"name,omitempty"`
+	Seat  int    `json:"seat"`
+	Hand  []Card `json:"hand"`
+	Token string `json:"token,omitempty"`
+}
+
+type SnapshotAction struct {
+	ID           string           `json:"id"`
+	PlayerID     string           `json:"playerId"`
+	Type         ActionType       `json:"type"`
+	Card         *Card            `json:"card,omitempty"`
+	AcceptedBy   []string         `json:"acceptedBy,omitempty"`
+	ChallengedBy []string         `json:"challengedBy,omitempty"`
+	Resolved     bool             `json:"resolved,omitempty"`
+	Resolution   ActionResolution `json:"resolution,omitempty"`
+	ResolvedBy   string           `json:"resolvedBy,omitempty"`
+	Overturned   bool             `json:"overturned,omitempty"`
+}
+
+type SnapshotSettings struct {
+	AppealsEnabled           bool    `json:"appealsEnabled"`
+	AppealWindowSeconds      int64   `json:"appealWindowSeconds"`
+	AppealMajority           float64 `json:"appealMajority"`
+	RevealRulebookOnEnd      bool    `json:"revealRulebookOnEnd"`
+	WrongChallengerPenalty   int     `json:"wrongChallengerPenalty"`
+	WrongAcceptorPenalty     int     `json:"wrongAcceptorPenalty"`
+	CorrectChallengerDiscard int     `json:"correctChallengerDiscard"`
+}
+
+type SnapshotRule struct {
+	ID        string `json:"id"`
+	Text      string `json:"text"`
+	CreatedAt int64  `json:"createdAt"`
+	AddedBy   string `json:"addedBy"`
+}
+
+type SnapshotSession struct {
+	Round           int                 `json:"round"`
+	Results         []SnapshotRound     `json:"results,omitempty"`
+	PendingRuleFrom string              `json:"pendingRuleFrom,omitempty"`
+	Standings       map[string]Standing `json:"standings,omitempty"`
+	Ended           bool                `json:"ended,omitempty"`
+}
+
+type SnapshotRound struct {
+	Round    int    `json:"round"`
+	WinnerID string `json:"winnerId"`
+	EndedAt  int64  `json:"endedAt"`
+}
+
+type SnapshotEvent struct {
+	Seq         int64     `json:"seq"`
+	Type        EventType `json:"type"`
+	PlayerID    string    `json:"playerId,omitempty"`
+	ActionID    string    `json:"actionId,omitempty"`
+	ActionType  string    `json:"actionType,omitempty"`
+	Card        *Card     `json:"card,omitempty"`
+	Penalty     int       `json:"penalty,omitempty"`
+	Discarded   int       `json:"discarded,omitempty"`
+	Overturn    bool      `json:"overturn,omitempty"`
+	Resolution  string    `json:"resolution,omitempty"`
+	Challengers []string  `json:"challengers,omitempty"`
+	Players     []string  `json:"players,omitempty"`
+	Dealt       int       `json:"dealt,omitempty"`
+	Timestamp   int64     `json:"timestamp"`
+}
+
+// SnapshotRNG lets a restored game continue the exact card sequence it would
+// have dealt. A zero seed has the importing server pick a new one; downloaded
+// snapshots are always redacted to that.
+type SnapshotRNG struct {
+	Seed  int64 `json:"seed"`
+	Calls int64 `json:"calls"`
+}
+
+func (g *Game) Snapshot() (Snapshot, error) {
+	events, err := g.Events()
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	g.random()
+
+	s := Snapshot{
+		Version:    SnapshotVersion,
+		ExportedAt: time.Now().Unix(),
+		RNG: SnapshotRNG{
+			Seed:  g.Seed,
+			Calls: g.rngSource.calls,
+		},
+		Game: SnapshotGame{
+			ID:                   g.ID,
+			Status:               g.Status,
+			AdminID:              g.AdminID,
+			CurrentAction:        toSnapshotAction(g.CurrentAction),
+			TopCard:              g.TopCard,
+			WinnerID:             g.WinnerID,
+			LastSuccessfulAction: toSnapshotAction(g.LastSuccessfulAction),
+			LastRejectedAction:   toSnapshotAction(g.LastRejectedAction),
+			Settings: SnapshotSettings{
+				AppealsEnabled:           g.Settings.AppealsEnabled,
+				AppealWindowSeconds:      int64(g.Settings.AppealWindow / time.Second),
+				AppealMajority:           g.Settings.AppealMajority,
+				RevealRulebookOnEnd:      g.Settings.RevealRulebookOnEnd,
+				WrongChallengerPenalty:   g.Settings.ChallengePolicy.WrongChallengerPenalty,
+				WrongAcceptorPenalty:     g.Settings.ChallengePolicy.WrongAcceptorPenalty,
+				CorrectChallengerDiscard: g.Settings.ChallengePolicy.CorrectChallengerDiscard,
+			},
+			Session: SnapshotSession{
+				Round:           g.Session.Round,
+				PendingRuleFrom: g.Session.PendingRuleFrom,
+				Standings:       g.Session.Standings,
+				Ended:           g.Session.Ended,
+			},
+		},
+	}
+
+	for _, p := range g.Players {
+		sp := SnapshotPlayer{ID: p.ID, Name: p.Name, Seat: p.Seat, Hand: []Card{}, Token: p.Token}
+		for _, c := range p.Hand {
+			sp.Hand = append(sp.Hand, *c)
+		}
+		s.Game.Players = append(s.Game.Players, sp)
+	}
+
+	for id := range g.Judges {
+		if s.Game.Judges == nil {
+			s.Game.Judges = make(map[string][]Permission)
+		}
+		for _, perm := range allPermissions {
+			if g.Judges[id][perm] {
+				s.Game.Judges[id] = append(s.Game.Judges[id], perm)
+			}
+		}
+	}
+
+	for _, r := range g.Rulebook {
+		s.Game.Rulebook = append(s.Game.Rulebook, SnapshotRule{
+			ID:        r.ID,
+			Text:      r.Text,
+			CreatedAt: r.CreatedAt.Unix(),
+			AddedBy:   r.AddedBy,
+		})
+	}
+
+	for _, r := range g.Session.Results {
+		s.Game.Session.Results = append(s.Game.Session.Results, SnapshotRound{
+			Round:    r.Round,
+			WinnerID: r.WinnerID,
+			EndedAt:  r.EndedAt.Unix(),
+		})
+	}
+
+	for _, e := range events {
+		s.Events = append(s.Events, SnapshotEvent{
+			Seq:         e.Seq,
+			Type:        e.Type,
+			PlayerID:    e.PlayerID,
+			ActionID:    e.ActionID,
+			ActionType:  e.ActionType,
+			Card:        e.Card,
+			Penalty:     e.Penalty,
+			Discarded:   e.Discarded,
+			Overturn:    e.Overturn,
+			Resolution:  e.Resolution,
+			Challengers: e.Challengers,
+			Players:     e.Players,
+			Dealt:       e.Dealt,
+			Timestamp:   e.Timestamp,
+		})
+	}
+
+	return s, nil
+}
+
+// Redacted is the snapshot as it may leave the server: without the seat
+// tokens, which would let its holder act for every player, or the RNG state,
+// which would reveal every card still to be dealt.
+func (s Snapshot) Redacted() Snapshot {
+	players := make([]SnapshotPlayer, len(s.Game.Players))
+	for i, p := range s.Game.Players {
+		p.Token = ""
+		players[i] = p
+	}
+	s.Game.Players = players
+	s.RNG = SnapshotRNG{}
+	return s
+}
+
+// Sealed returns the snapshot with its checksum filled in.
+func (s Snapshot) Sealed() Snapshot {
+	s.Checksum = s.checksum()
+	return s
+}
+
+func (s Snapshot) checksum() string {
+	s.Checksum = ""
+	b, err := json.Marshal(s)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func toSnapshotAction(a *Action) *SnapshotAction {
+	if a == nil {
+		return nil
+	}
+	return &SnapshotAction{
+		ID:           a.ID,
+		PlayerID:     a.PlayerID,
+		Type:         a.Type,
+		Card:         a.Card,
+		AcceptedBy:   sortedIDs(a.AcceptedBy),
+		ChallengedBy: sortedIDs(a.ChallengedBy),
+		Resolved:     a.Resolved,
+		Resolution:   a.Resolution,
+		ResolvedBy:   a.ResolvedBy,
+		Overturned:   a.Overturned,
+	}
+}
+
+func fromSnapshotAction(a *SnapshotAction) *Action {
+	if a == nil {
+		return nil
+	}
+	action := &Action{
+		ID:           a.ID,
+		PlayerID:     a.PlayerID,
+		Type:         a.Type,
+		Card:         a.Card,
+		AcceptedBy:   make(map[string]bool),
+		ChallengedBy: make(map[string]bool),
+		Resolved:     a.Resolved,
+		Resolution:   a.Resolution,
+		ResolvedBy:   a.ResolvedBy,
+		Overturned:   a.Overturned,
+	}
+	for _, id := range a.AcceptedBy {
+		action.AcceptedBy[id] = true
+	}
+	for _, id := range a.ChallengedBy {
+		action.ChallengedBy[id] = true
+	}
+	return action
+}
+
+func validCard(c *Card) bool {
+	if c == nil {
+		return false
+	}
+	rankOK, suitOK := false, false
+	for _, r := range ranks {
+		rankOK = rankOK || r == c.Rank
+	}
+	for _, s := range suits {
+		suitOK = suitOK || s == c.Suit
+	}
+	return rankOK && suitOK
+}
+
+// Validate checks a snapshot is internally consistent before it is imported.
+func (s Snapshot) Validate() error {
+	if s.Version < 1 || s.Version > SnapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d", s.Version)
+	}
+	if s.Checksum != "" && s.Checksum != s.checksum() {
+		return errors.New("snapshot checksum does not match its contents")
+	}
+
+	sg := s.Game
+	if sg.ID == "" {
+		return errors.New("snapshot has no game id")
+	}
+
+	switch sg.Status {
+	case GameWaiting, GameActive, GameEnded:
+	default:
+		return fmt.Errorf("invalid game status %q", sg.Status)
+	}
+
+	seated := make(map[string]bool)
+	for _, p := range sg.Players {
+		if p.ID == "" {
+			return errors.New("player with empty id")
+		}
+		if seated[p.ID] {
+			return fmt.Errorf("duplicate player %q", p.ID)
+		}
+		seated[p.ID] = true
+		for i := range p.Hand {
+			if !validCard(&p.Hand[i]) {
+				return fmt.Errorf("invalid card in %s's hand", p.ID)
+			}
+		}
+	}
+
+	if !seated[sg.AdminID] {
+		return errors.New("admin is not a player")
+	}
+
+	for id, perms := range sg.Judges {
+		if !seated[id] {
+			return fmt.Errorf("judge %q is not a player", id)
+		}
+		for _, perm := range perms {
+			if !validPermission(perm) {
+				return fmt.Errorf("unknown permission %q", perm)
+			}
+		}
+	}
+
+	if sg.TopCard != nil && !validCard(sg.TopCard) {
+		return errors.New("invalid top card")
+	}
+
+	if a := sg.CurrentAction; a != nil {
+		if !seated[a.PlayerID] {
+			return errors.New("current action belongs to an unknown player")
+		}
+		if a.Type != ActionPlayCard && a.Type != ActionDraw {
+			return fmt.Errorf("invalid action type %q", a.Type)
+		}
+		if a.Type == ActionPlayCard && !validCard(a.Card) {
+			return errors.New("invalid card in current action")
+		}
+	}
+
+	if s.RNG.Calls < 0 || s.RNG.Calls > maxRNGCalls {
+		return fmt.Errorf("rng position must be between 0 and %d", maxRNGCalls)
+	}
+	if s.RNG.Seed == 0 && s.RNG.Calls != 0 {
+		return errors.New("rng position given without a seed")
+	}
+
+	var lastSeq int64
+	for _, e := range s.Events {
+		if e.Seq <= lastSeq {
+			return errors.New("events are not in sequence order")
+		}
+		lastSeq = e.Seq
+	}
+
+	return s.settings().Validate()
+}
+
+func (s Snapshot) settings() Settings {
+	ss := s.Game.Settings
+	return Settings{
+		AppealsEnabled:      ss.AppealsEnabled,
+		AppealWindow:        time.Duration(ss.AppealWindowSeconds) * time.Second,
+		AppealMajority:      ss.AppealMajority,
+		RevealRulebookOnEnd: ss.RevealRulebookOnEnd,
+		ChallengePolicy: ChallengePolicy{
+			WrongChallengerPenalty:   ss.WrongChallengerPenalty,
+			WrongAcceptorPenalty:     ss.WrongAcceptorPenalty,
+			CorrectChallengerDiscard: ss.CorrectChallengerDiscard,
+		},
+	}
+}
+
+// importMode says where a snapshot being imported came from.
+type importMode int
+
+const (
+	// importOriginal keeps the snapshot's code, which must be free.
+	importOriginal importMode = iota
+	// importFresh gives the game a newly allocated code.
+	importFresh
+	// importRestore reloads a game this server saved itself: its seat tokens
+	// are kept and its code is still reserved in the store.
+	importRestore
+)
+
+// ImportSnapshot validates a snapshot and registers it as a live game under
+// its original code. The code must fit this server's format and be free to
+// reserve. Every seat is issued a new token, whatever the snapshot holds.
+func (reg *Registry) ImportSnapshot(s Snapshot) (*Game, error) {
+	return reg.importSnapshot(s, importOriginal)
+}
+
+// ResumeSnapshot registers a sealed snapshot as a new game under a freshly
+// allocated code, so whoever uploads it cannot claim a code in use. Every
+// seat is issued a new token.
+func (reg *Registry) ResumeSnapshot(s Snapshot) (*Game, error) {
+	if s.Checksum == "" {
+		return nil, errors.New("snapshot has no checksum")
+	}
+	return reg.importSnapshot(s, importFresh)
+}
+
+func (reg *Registry) importSnapshot(s Snapshot, mode importMode) (*Game, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	sg := s.Game
+	if mode == importOriginal && !reg.config.Codes.fits(sg.ID) {
+		return nil, fmt.Errorf("game code %q does not fit this server's code format", sg.ID)
+	}
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if reg.closed {
+		return nil, ErrShuttingDown
+	}
+	if mode == importFresh {
+		code, err := reg.allocateCode()
+		if err != nil {
+			return nil, err
+		}
+		sg.ID = code
+	} else if _, exists := reg.games[sg.ID]; exists {
+		return nil, errors.New("game code already in use")
+	}
+
+	g := &Game{
+		ID:                   sg.ID,
+		Status:               sg.Status,
+		AdminID:              sg.AdminID,
+		CurrentAction:        fromSnapshotAction(sg.CurrentAction),
+		TopCard:              sg.TopCard,
+		WinnerID:             sg.WinnerID,
+		LastSuccessfulAction: fromSnapshotAction(sg.LastSuccessfulAction),
+		LastRejectedAction:   fromSnapshotAction(sg.LastRejectedAction),
+		Settings:             s.settings(),
+		Session: Session{
+			Round:           sg.Session.Round,
+			PendingRuleFrom: sg.Session.PendingRuleFrom,
+			Standings:       sg.Session.Standings,
+			Ended:           sg.Session.Ended,
+		},
+		Seed:     s.RNG.Seed,
+		registry: reg,
+	}
+	if g.Seed == 0 {
+		g.Seed = time.Now().UnixNano()
+	}
+	g.rngSource = newCountingSource(g.Seed, s.RNG.Calls)
+	g.rng = rand.New(g.rngSource)
+
+	for _, sp := range sg.Players {
+		p := &Player{ID: sp.ID, Name: sp.Name, Seat: sp.Seat, IsAdmin: sp.ID == sg.AdminID, Token: sp.Token}
+		if mode != importRestore || p.Token == "" {
+			p.Token = newSeatToken()
+		}
+		for _, c := range sp.Hand {
+			card := c
+			p.Hand = append(p.Hand, &card)
+		}
+		g.Players = append(g.Players, p)
+	}
+
+	for id, perms := range sg.Judges {
+		for _, perm := range perms {
+			if g.Judges == nil {
+				g.Judges = make(map[string]map[Permission]bool)
+			}
+			if g.Judges[id] == nil {
+				g.Judges[id] = make(map[Permission]bool)
+			}
+			g.Judges[id][perm] = true
+		}
+	}
+
+	for _, r := range sg.Rulebook {
+		g.Rulebook = append(g.Rulebook, &Rule{
+			ID:        r.ID,
+			Text:      r.Text,
+			CreatedAt: time.Unix(r.CreatedAt, 0),
+			AddedBy:   r.AddedBy,
+		})
+		if n, err := strconv.Atoi(r.ID); err == nil && n > g.ruleSeq {
+			g.ruleSeq = n
+		}
+	}
+
+	for _, r := range sg.Session.Results {
+		g.Session.Results = append(g.Session.Results, RoundResult{
+			Round:    r.Round,
+			WinnerID: r.WinnerID,
+			EndedAt:  time.Unix(r.EndedAt, 0),
+		})
+	}
+
+	// a restored game kept its code reserved across the restart, and a
+	// fresh one was reserved by allocateCode
+	if mode == importOriginal {
+		reserved, err := reg.store.ReserveCode(g.ID)
+		if err != nil {
+			return nil, err
+		}
+		if !reserved {
+			return nil, errors.New("game code already in use")
+		}
+	}
+	if err := reg.store.DeleteEvents(g.ID); err != nil {
+		return nil, err
+	}
+	for _, se := range s.Events {
+		e := Event{
+			Seq:         se.Seq,
+			Type:        se.Type,
+			PlayerID:    se.PlayerID,
+			ActionID:    se.ActionID,
+			ActionType:  se.ActionType,
+			Card:        se.Card,
+			Penalty:     se.Penalty,
+			Discarded:   se.Discarded,
+			Overturn:    se.Overturn,
+			Resolution:  se.Resolution,
+			Challengers: se.Challengers,
+			Players:     se.Players,
+			Dealt:       se.Dealt,
+			Timestamp:   se.Timestamp,
+		}
+		if err := reg.store.AppendEvent(g.ID, e); err != nil {
+			return nil, err
+		}
+		g.eventSeq = e.Seq
+		g.RecentEvents = append(g.RecentEvents, e)
+		g.trimRecentEvents()
+	}
+
+	g.touch()
+	reg.games[g.ID] = g
+	return g, nil
+}
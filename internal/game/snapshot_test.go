@@ -0,0 +1,278 @@
+package game
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func validSnapshot() Snapshot {
+	return Snapshot{
+		Version: SnapshotVersion,
+		Game: SnapshotGame{
+			ID:      "ABCD",
+			Status:  GameActive,
+			AdminID: "a",
+			Players: []SnapshotPlayer{
+				{ID: "a", Hand: []Card{{Rank: "7", Suit: "hearts"}}},
+				{ID: "b", Hand: []Card{}},
+			},
+			TopCard: &Card{Rank: "K", Suit: "spades"},
+			Settings: SnapshotSettings{
+				AppealWindowSeconds: 30,
+				AppealMajority:      0.5,
+			},
+			Session: SnapshotSession{Round: 1},
+		},
+		Events: []SnapshotEvent{{Seq: 1, Type: EventAction}, {Seq: 2, Type: EventPenalty}},
+		RNG:    SnapshotRNG{Seed: 42, Calls: 30},
+	}
+}
+
+func TestSnapshotValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(s *Snapshot)
+		wantErr string
+	}{
+		{name: "valid", modify: func(s *Snapshot) {}},
+		{name: "redacted", modify: func(s *Snapshot) { *s = s.Redacted() }},
+		{name: "future version", modify: func(s *Snapshot) { s.Version = SnapshotVersion + 1 }, wantErr: "version"},
+		{name: "no id", modify: func(s *Snapshot) { s.Game.ID = "" }, wantErr: "no game id"},
+		{name: "bad status", modify: func(s *Snapshot) { s.Game.Status = "PAUSED" }, wantErr: "status"},
+		{name: "duplicate player", modify: func(s *Snapshot) { s.Game.Players[1].ID = "a" }, wantErr: "duplicate"},
+		{name: "admin not seated", modify: func(s *Snapshot) { s.Game.AdminID = "z" }, wantErr: "admin"},
+		{name: "invalid card", modify: func(s *Snapshot) { s.Game.Players[0].Hand[0].Rank = "1" }, wantErr: "invalid card"},
+		{name: "unknown judge", modify: func(s *Snapshot) { s.Game.Judges = map[string][]Permission{"z": nil} }, wantErr: "judge"},
+		{name: "events out of order", modify: func(s *Snapshot) { s.Events[1].Seq = 1 }, wantErr: "sequence"},
+		{name: "rng wound too far", modify: func(s *Snapshot) { s.RNG.Calls = maxRNGCalls + 1 }, wantErr: "rng"},
+		{name: "rng wound back", modify: func(s *Snapshot) { s.RNG.Calls = -1 }, wantErr: "rng"},
+		{name: "rng without seed", modify: func(s *Snapshot) { s.RNG.Seed = 0 }, wantErr: "seed"},
+		{name: "bad settings", modify: func(s *Snapshot) { s.Game.Settings.AppealMajority = 2 }, wantErr: "majority"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := validSnapshot()
+			tt.modify(&s)
+
+			err := s.Validate()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.wantErr != "" && err == nil:
+				t.Fatalf("want error containing %q", tt.wantErr)
+			case err != nil && !strings.Contains(err.Error(), tt.wantErr):
+				t.Fatalf("error %q does not mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestImportSnapshot(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(t *testing.T, reg *Registry, s *Snapshot)
+		wantErr string
+	}{
+		{name: "fresh server", prepare: func(t *testing.T, reg *Registry, s *Snapshot) {}},
+		{
+			name:    "code outside the format",
+			prepare: func(t *testing.T, reg *Registry, s *Snapshot) { s.Game.ID = "../AB" },
+			wantErr: "code format",
+		},
+		{
+			name:    "blocked code",
+			prepare: func(t *testing.T, reg *Registry, s *Snapshot) { s.Game.ID = "POOP" },
+			wantErr: "code format",
+		},
+		{
+			name: "code reserved in the store",
+			prepare: func(t *testing.T, reg *Registry, s *Snapshot) {
+				if _, err := reg.store.ReserveCode(s.Game.ID); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: "in use",
+		},
+		{
+			name: "code held by a live game",
+			prepare: func(t *testing.T, reg *Registry, s *Snapshot) {
+				if _, err := reg.ImportSnapshot(*s); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: "in use",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, orig := newTestGame(t, "a", "b")
+			exported, err := orig.Snapshot()
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := exported.Redacted()
+
+			reg := NewRegistry(DefaultConfig(), NewMemoryStore())
+			tt.prepare(t, reg, &s)
+
+			g, err := reg.ImportSnapshot(s)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one mentioning %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if g.Seed == 0 || g.Seed == orig.Seed {
+				t.Errorf("imported game kept seed %d, want a new one", g.Seed)
+			}
+			for i, p := range g.Players {
+				if p.Token == "" || p.Token == orig.Players[i].Token {
+					t.Errorf("%s kept or lacks a seat token", p.ID)
+				}
+				if len(p.Hand) != len(orig.Players[i].Hand) {
+					t.Errorf("%s holds %d cards, want %d", p.ID, len(p.Hand), len(orig.Players[i].Hand))
+				}
+			}
+			events, _ := reg.store.Events(g.ID)
+			if len(events) != len(exported.Events) {
+				t.Errorf("imported %d events, want %d", len(events), len(exported.Events))
+			}
+		})
+	}
+}
+
+func TestResumeSnapshot(t *testing.T) {
+	tests := []struct {
+		name    string
+		edit    func(s *Snapshot)
+		unseal  bool
+		wantErr string
+	}{
+		{name: "sealed snapshot"},
+		{name: "unsealed snapshot", unseal: true, wantErr: "no checksum"},
+		{
+			name:    "edited after sealing",
+			edit:    func(s *Snapshot) { s.Game.Players[0].Hand = s.Game.Players[0].Hand[1:] },
+			wantErr: "checksum",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg, orig := newTestGame(t, "a", "b")
+			exported, err := orig.Snapshot()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the snapshot travels as JSON, as it does through a download
+			b, err := json.Marshal(exported.Redacted().Sealed())
+			if err != nil {
+				t.Fatal(err)
+			}
+			var s Snapshot
+			if err := json.Unmarshal(b, &s); err != nil {
+				t.Fatal(err)
+			}
+			if tt.edit != nil {
+				tt.edit(&s)
+			}
+			if tt.unseal {
+				s.Checksum = ""
+			}
+
+			// the original game is still live on the same server
+			g, err := reg.ResumeSnapshot(s)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one mentioning %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if g.ID == orig.ID {
+				t.Errorf("resumed game kept the live code %s", g.ID)
+			}
+			for i, p := range g.Players {
+				if p.Token == "" || p.Token == orig.Players[i].Token {
+					t.Errorf("%s kept or lacks a seat token", p.ID)
+				}
+				if len(p.Hand) != len(orig.Players[i].Hand) {
+					t.Errorf("%s holds %d cards, want %d", p.ID, len(p.Hand), len(orig.Players[i].Hand))
+				}
+			}
+			events, _ := reg.store.Events(g.ID)
+			if len(events) != len(exported.Events) {
+				t.Errorf("resumed %d events, want %d", len(events), len(exported.Events))
+			}
+		})
+	}
+}
+
+func TestRedactedLeavesOriginalIntact(t *testing.T) {
+	s := validSnapshot()
+	s.Game.Players[0].Token = "secret"
+
+	r := s.Redacted()
+	if r.Game.Players[0].Token != "" || r.RNG != (SnapshotRNG{}) {
+		t.Errorf("redacted snapshot kept secrets: %+v, %+v", r.Game.Players[0], r.RNG)
+	}
+	if s.Game.Players[0].Token != "secret" {
+		t.Error("redacting changed the original snapshot")
+	}
+}
+
+func TestFlushAndRestore(t *testing.T) {
+	store := NewMemoryStore()
+	reg := NewRegistry(DefaultConfig(), store)
+	g, err := reg.CreateGame(&Player{ID: "a", IsAdmin: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reg.JoinGame(g.ID, &Player{ID: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.StartGame("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := NewRegistry(DefaultConfig(), store)
+	n, err := restarted.Restore()
+	if err != nil || n != 1 {
+		t.Fatalf("restored %d games, err %v; want 1", n, err)
+	}
+
+	r, err := restarted.GetGame(g.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, p := range r.Players {
+		if p.Token != g.Players[i].Token {
+			t.Errorf("%s lost their seat token", p.ID)
+		}
+		if len(p.Hand) != len(g.Players[i].Hand) || *p.Hand[0] != *g.Players[i].Hand[0] {
+			t.Errorf("%s's hand changed", p.ID)
+		}
+	}
+	if next, want := r.newCard(), g.newCard(); *next != *want {
+		t.Errorf("restored game deals %v, want %v", next, want)
+	}
+
+	left, _ := store.Snapshots()
+	if len(left) != 0 {
+		t.Errorf("%d snapshots left in the store after restore", len(left))
+	}
+}
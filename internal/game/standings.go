@@ -0,0 +1,87 @@
+package game
+
+import "sort"
+
+type Standing struct {
+	PlayerID             string
+	RoundWins            int
+	PenaltyCards         int
+	SuccessfulChallenges int
+	RejectedPlays        int
+}
+
+// tally folds an event into the session standings. Standings are derived
+// only from events so they survive round resets and can be rebuilt from a log.
+func (g *Game) tally(e Event) {
+	g.score(e, 1)
+}
+
+// untally takes back what tally counted for an event, for an overturned
+// resolution.
+func (g *Game) untally(e Event) {
+	g.score(e, -1)
+}
+
+func (g *Game) score(e Event, n int) {
+	switch e.Type {
+	case EventPenalty:
+		g.updateStanding(e.PlayerID, func(s *Standing) {
+			s.PenaltyCards += n * e.Penalty
+		})
+	case EventRoundWon:
+		g.updateStanding(e.PlayerID, func(s *Standing) {
+			s.RoundWins += n
+		})
+	case EventResolution:
+		if e.Resolution != string(ResolutionReject) {
+			return
+		}
+		g.updateStanding(e.PlayerID, func(s *Standing) {
+			s.RejectedPlays += n
+		})
+		for _, pid := range e.Challengers {
+			g.updateStanding(pid, func(s *Standing) {
+				s.SuccessfulChallenges += n
+			})
+		}
+	}
+}
+
+func (g *Game) updateStanding(playerID string, update func(*Standing)) {
+	if playerID == "" {
+		return
+	}
+	if g.Session.Standings == nil {
+		g.Session.Standings = make(map[string]Standing)
+	}
+	s := g.Session.Standings[playerID]
+	s.PlayerID = playerID
+	update(&s)
+	g.Session.Standings[playerID] = s
+}
+
+// Standings returns every current player's standing, plus anyone who left
+// after scoring, ranked by round wins and then by fewest penalty cards.
+func (g *Game) Standings() []Standing {
+	seen := make(map[string]bool)
+	var standings []Standing
+	for _, p := range g.Players {
+		s := g.Session.Standings[p.ID]
+		s.PlayerID = p.ID
+		standings = append(standings, s)
+		seen[p.ID] = true
+	}
+	for id, s := range g.Session.Standings {
+		if !seen[id] {
+			standings = append(standings, s)
+		}
+	}
+
+	sort.SliceStable(standings, func(i, j int) bool {
+		if standings[i].RoundWins != standings[j].RoundWins {
+			return standings[i].RoundWins > standings[j].RoundWins
+		}
+		return standings[i].PenaltyCards < standings[j].PenaltyCards
+	})
+	return standings
+}
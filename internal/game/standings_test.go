@@ -0,0 +1,174 @@
+package game
+
+import "testing"
+
+func TestStandings(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []Event
+		want   []Standing
+	}{
+		{
+			name: "no events",
+			want: []Standing{{PlayerID: "a"}, {PlayerID: "b"}},
+		},
+		{
+			name: "wins rank first",
+			events: []Event{
+				{Type: EventPenalty, PlayerID: "b", Penalty: 4},
+				{Type: EventRoundWon, PlayerID: "b"},
+			},
+			want: []Standing{
+				{PlayerID: "b", RoundWins: 1, PenaltyCards: 4},
+				{PlayerID: "a"},
+			},
+		},
+		{
+			name: "fewer penalties break ties",
+			events: []Event{
+				{Type: EventPenalty, PlayerID: "a", Penalty: 2},
+				{Type: EventPenalty, PlayerID: "b", Penalty: 1},
+			},
+			want: []Standing{
+				{PlayerID: "b", PenaltyCards: 1},
+				{PlayerID: "a", PenaltyCards: 2},
+			},
+		},
+		{
+			name: "rejection credits challengers",
+			events: []Event{
+				{Type: EventResolution, PlayerID: "a", Resolution: string(ResolutionReject), Challengers: []string{"b"}},
+				{Type: EventResolution, PlayerID: "a", Resolution: string(ResolutionAccept), Challengers: []string{"b"}},
+			},
+			want: []Standing{
+				{PlayerID: "a", RejectedPlays: 1},
+				{PlayerID: "b", SuccessfulChallenges: 1},
+			},
+		},
+		{
+			name: "departed players keep their score",
+			events: []Event{
+				{Type: EventRoundWon, PlayerID: "gone"},
+			},
+			want: []Standing{
+				{PlayerID: "gone", RoundWins: 1},
+				{PlayerID: "a"},
+				{PlayerID: "b"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &Game{Players: []*Player{{ID: "a"}, {ID: "b"}}}
+			for _, e := range tt.events {
+				g.tally(e)
+			}
+
+			got := g.Standings()
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d standings, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("standing %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestEndSessionRecordsEvent(t *testing.T) {
+	reg, g := newTestGame(t, "a", "b")
+
+	if err := g.EndSession("b"); err == nil {
+		t.Fatal("non-admin ended the session")
+	}
+	if err := g.EndSession("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := reg.store.Events(g.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := events[len(events)-1]
+	if last.Type != EventSessionEnded || last.PlayerID != "a" {
+		t.Errorf("last event = %+v, want SESSION_ENDED by a", last)
+	}
+
+	frames, err := reg.Replay(g.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := frames[len(frames)-1].Status; got != GameEnded {
+		t.Errorf("replayed status = %s, want %s", got, GameEnded)
+	}
+}
+
+func TestNegativePenaltyRefused(t *testing.T) {
+	tests := []struct {
+		name  string
+		apply func(g *Game) error
+	}{
+		{
+			name:  "apply penalty",
+			apply: func(g *Game) error { return g.ApplyPenalty("b", -4) },
+		},
+		{
+			name:  "penalize player",
+			apply: func(g *Game) error { return g.PenalizePlayer("a", "b", -4) },
+		},
+		{
+			name: "resolve action",
+			apply: func(g *Game) error {
+				b := g.Players[1]
+				a := &Action{
+					ID:           "act",
+					PlayerID:     "b",
+					Type:         ActionPlayCard,
+					Card:         b.Hand[0],
+					AcceptedBy:   map[string]bool{},
+					ChallengedBy: map[string]bool{},
+				}
+				if err := g.ProposeAction(a); err != nil {
+					t.Fatal(err)
+				}
+				return g.ResolveAction("a", ResolutionAcceptWithPenalty, -4)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg, g := newTestGame(t, "a", "b")
+			before, err := reg.store.Events(g.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := tt.apply(g); err == nil {
+				t.Fatal("negative penalty accepted")
+			}
+
+			if got := len(mustPlayer(t, g, "b").Hand); got != 7 {
+				t.Errorf("b holds %d cards, want 7", got)
+			}
+			if got := standing(g, "b").PenaltyCards; got != 0 {
+				t.Errorf("b has %d penalty cards, want 0", got)
+			}
+			if g.CurrentAction != nil && g.CurrentAction.Resolved {
+				t.Error("action resolved despite the refused penalty")
+			}
+			after, err := reg.store.Events(g.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, e := range after[len(before):] {
+				if e.Type == EventPenalty || e.Type == EventResolution {
+					t.Errorf("logged %s event for a refused penalty", e.Type)
+				}
+			}
+		})
+	}
+}
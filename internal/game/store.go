@@ -0,0 +1,139 @@
+package game
+
+import (
+	"sync"
+	"time"
+)
+
+// Store retains the full event log of every game. RecentEvents on Game is
+// only the rolling window shown to players. It also holds the game codes in
+// use, so a code is not handed out twice by a store that outlives the
+// process, and the snapshots games are saved as when the server stops.
+type Store interface {
+	AppendEvent(gameID string, e Event) error
+	Events(gameID string) ([]Event, error)
+	// DeleteEvents drops a game's log along with any retention set for it.
+	DeleteEvents(gameID string) error
+
+	// RetainHistory keeps the log of a game that is no longer live until
+	// the given time, or for good if it is zero; RetainedHistories lists
+	// every such game.
+	RetainHistory(gameID string, until time.Time) error
+	RetainedHistories() (map[string]time.Time, error)
+
+	// ReserveCode claims a code, reporting false if it is already taken.
+	ReserveCode(code string) (bool, error)
+	ReleaseCode(code string) error
+	// Codes lists every reserved code.
+	Codes() ([]string, error)
+
+	SaveSnapshot(s Snapshot) error
+	Snapshots() ([]Snapshot, error)
+	DeleteSnapshot(gameID string) error
+}
+
+type MemoryStore struct {
+	mu        sync.RWMutex
+	events    map[string][]Event
+	retained  map[string]time.Time
+	codes     map[string]bool
+	snapshots map[string]Snapshot
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		events:    make(map[string][]Event),
+		retained:  make(map[string]time.Time),
+		codes:     make(map[string]bool),
+		snapshots: make(map[string]Snapshot),
+	}
+}
+
+func (s *MemoryStore) AppendEvent(gameID string, e Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events[gameID] = append(s.events[gameID], e)
+	return nil
+}
+
+func (s *MemoryStore) Events(gameID string) ([]Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]Event(nil), s.events[gameID]...), nil
+}
+
+func (s *MemoryStore) DeleteEvents(gameID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.events, gameID)
+	delete(s.retained, gameID)
+	return nil
+}
+
+func (s *MemoryStore) RetainHistory(gameID string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retained[gameID] = until
+	return nil
+}
+
+func (s *MemoryStore) RetainedHistories() (map[string]time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[string]time.Time, len(s.retained))
+	for id, until := range s.retained {
+		out[id] = until
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) ReserveCode(code string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.codes[code] {
+		return false, nil
+	}
+	s.codes[code] = true
+	return true, nil
+}
+
+func (s *MemoryStore) ReleaseCode(code string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.codes, code)
+	return nil
+}
+
+func (s *MemoryStore) Codes() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]string, 0, len(s.codes))
+	for code := range s.codes {
+		out = append(out, code)
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) SaveSnapshot(snapshot Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshots[snapshot.Game.ID] = snapshot
+	return nil
+}
+
+func (s *MemoryStore) Snapshots() ([]Snapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]Snapshot, 0, len(s.snapshots))
+	for _, snapshot := range s.snapshots {
+		out = append(out, snapshot)
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) DeleteSnapshot(gameID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.snapshots, gameID)
+	return nil
+}
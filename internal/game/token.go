@@ -0,0 +1,27 @@
+package game
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+)
+
+// ErrInvalidToken is returned when a seat token does not match the player
+// it claims to act for.
+var ErrInvalidToken = errors.New("invalid seat token")
+
+func newSeatToken() string {
+	return rand.Text()
+}
+
+// Authenticate checks that token is the seat token issued to playerID.
+func (g *Game) Authenticate(playerID, token string) error {
+	p, err := g.findPlayer(playerID)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(p.Token)) != 1 {
+		return ErrInvalidToken
+	}
+	return nil
+}
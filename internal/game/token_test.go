@@ -0,0 +1,40 @@
+package game
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRejoinGame(t *testing.T) {
+	reg, g := newTestGame(t, "a", "b")
+	a, b := mustPlayer(t, g, "a"), mustPlayer(t, g, "b")
+
+	if a.Token == "" || b.Token == "" || a.Token == b.Token {
+		t.Fatalf("seat tokens not issued: %q, %q", a.Token, b.Token)
+	}
+
+	tests := []struct {
+		name    string
+		player  string
+		token   string
+		wantErr bool
+	}{
+		{name: "own token", player: "b", token: b.Token},
+		{name: "no token", player: "b", wantErr: true},
+		{name: "wrong token", player: "b", token: "guess", wantErr: true},
+		{name: "another seat's token", player: "b", token: a.Token, wantErr: true},
+		{name: "unknown player", player: "z", token: a.Token, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := reg.RejoinGame(g.ID, tt.player, tt.token)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("RejoinGame error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("error = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
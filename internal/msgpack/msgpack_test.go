@@ -0,0 +1,116 @@
+package msgpack
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type tagged struct {
+	Name     string            `json:"name"`
+	Optional string            `json:"optional,omitempty"`
+	Skipped  int               `json:"-"`
+	Raw      json.RawMessage   `json:"raw"`
+	Nested   map[string][]int  `json:"nested"`
+	Pointer  *tagged           `json:"pointer,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+}
+
+func TestRoundTrip(t *testing.T) {
+	keys := make(map[string]int)
+	for i := range 20 {
+		keys[strings.Repeat("k", i+1)] = i
+	}
+	long := make([]int, 70000)
+	for i := range long {
+		long[i] = i
+	}
+
+	tests := []struct {
+		name string
+		v    interface{}
+	}{
+		{name: "nil", v: nil},
+		{name: "booleans", v: []bool{true, false}},
+		{name: "integers", v: []int64{0, 1, 127, 128, 255, 256, -1, -32, -33, -128, -129, 32767, 32768, -32769, math.MaxInt32 + 1, math.MinInt32 - 1, 1 << 40, math.MinInt64}},
+		{name: "floats", v: []float64{1.5, -0.25, 1e300}},
+		{name: "strings", v: []string{"", strings.Repeat("a", 31), strings.Repeat("b", 32), strings.Repeat("c", 300), strings.Repeat("d", 70000), "héllo ✓"}},
+		{name: "long array", v: long},
+		{name: "large map", v: keys},
+		{name: "struct tags", v: tagged{
+			Name:    "ann",
+			Skipped: 3,
+			Raw:     json.RawMessage(`{"x":[1,2]}`),
+			Nested:  map[string][]int{"a": {1, 2}, "b": nil},
+			Pointer: &tagged{Name: "bob", Raw: json.RawMessage(`null`)},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packed, err := Marshal(tt.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ToJSON(packed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := json.Marshal(tt.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var gotValue, wantValue interface{}
+			if err := json.Unmarshal(got, &gotValue); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(want, &wantValue); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(gotValue, wantValue) {
+				t.Errorf("round trip = %.200s, want %.200s", got, want)
+			}
+		})
+	}
+}
+
+func TestMarshalIsCompact(t *testing.T) {
+	v := map[string]interface{}{"type": "PING", "clientTime": 1700000000000}
+	packed, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, _ := json.Marshal(v)
+	if len(packed) >= len(text) {
+		t.Errorf("msgpack is %d bytes, JSON %d", len(packed), len(text))
+	}
+}
+
+func TestToJSONRejectsMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty", data: nil},
+		{name: "truncated string", data: []byte{0xa5, 'a'}},
+		{name: "truncated length", data: []byte{0xcd, 0x01}},
+		{name: "array longer than the data", data: []byte{0xdd, 0xff, 0xff, 0xff, 0xff}},
+		{name: "map longer than the data", data: []byte{0xdf, 0xff, 0xff, 0xff, 0xff, 0xc0}},
+		{name: "unsupported type", data: []byte{0xc1}},
+		{name: "non-string key", data: []byte{0x81, 0x01, 0x02}},
+		{name: "trailing data", data: []byte{0xc0, 0xc0}},
+		{name: "nested too deeply", data: append(bytes.Repeat([]byte{0x91}, maxDepth+1), 0xc0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if out, err := ToJSON(tt.data); err == nil {
+				t.Errorf("ToJSON(% x) = %s, want an error", tt.data, out)
+			}
+		})
+	}
+}
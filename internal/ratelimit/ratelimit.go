@@ -0,0 +1,163 @@
+// Package ratelimit implements token buckets for throttling clients.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Clock is the time source of a bucket, so callers can drive limits with a
+// fake clock.
+type Clock interface {
+	Now() time.Time
+}
+
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Limit allows Burst requests at once, refilled at Rate requests per
+// second. A zero Rate means no limit.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+func (l Limit) Unlimited() bool {
+	return l.Rate <= 0
+}
+
+// Per returns a limit of n requests every d, all of which may come at once.
+func Per(n int, d time.Duration) Limit {
+	return Limit{Rate: float64(n) / d.Seconds(), Burst: n}
+}
+
+// String formats the limit as ParseLimit reads it.
+func (l Limit) String() string {
+	if l.Unlimited() {
+		return "off"
+	}
+	window := time.Duration(float64(l.Burst) / l.Rate * float64(time.Second))
+	return fmt.Sprintf("%d/%s", l.Burst, window.Round(time.Millisecond))
+}
+
+// ParseLimit reads a limit written as "n/duration", e.g. "10/2s" for ten
+// requests every two seconds, or "off" for no limit.
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "off" {
+		return Limit{}, nil
+	}
+
+	count, window, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("limit %q is not of the form n/duration", s)
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil || n < 1 {
+		return Limit{}, fmt.Errorf("limit %q must allow at least one request", s)
+	}
+	d, err := time.ParseDuration(window)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("limit %q needs a positive duration", s)
+	}
+	return Per(n, d), nil
+}
+
+type Bucket struct {
+	limit  Limit
+	tokens float64
+	last   time.Time
+}
+
+func NewBucket(limit Limit, now time.Time) *Bucket {
+	return &Bucket{limit: limit, tokens: float64(limit.Burst), last: now}
+}
+
+// Take spends one token. When none is left it reports how long until one
+// is.
+func (b *Bucket) Take(now time.Time) (bool, time.Duration) {
+	if b.limit.Unlimited() {
+		return true, 0
+	}
+
+	b.refill(now)
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := (1 - b.tokens) / b.limit.Rate
+	return false, time.Duration(math.Ceil(wait * float64(time.Second)))
+}
+
+func (b *Bucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(b.limit.Burst), b.tokens+elapsed*b.limit.Rate)
+		b.last = now
+	}
+}
+
+func (b *Bucket) full(now time.Time) bool {
+	b.refill(now)
+	return b.tokens >= float64(b.limit.Burst)
+}
+
+// Limiter keeps one bucket per key, e.g. per client IP. Buckets that have
+// refilled are dropped so idle keys do not accumulate.
+type Limiter struct {
+	clock Clock
+	limit Limit
+
+	mu      sync.Mutex
+	buckets map[string]*Bucket
+	swept   time.Time
+}
+
+func New(limit Limit, clock Clock) *Limiter {
+	if clock == nil {
+		clock = SystemClock{}
+	}
+	return &Limiter{
+		clock:   clock,
+		limit:   limit,
+		buckets: make(map[string]*Bucket),
+	}
+}
+
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	if l.limit.Unlimited() {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = NewBucket(l.limit, now)
+		l.buckets[key] = b
+	}
+	return b.Take(now)
+}
+
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < time.Minute {
+		return
+	}
+	for key, b := range l.buckets {
+		if b.full(now) {
+			delete(l.buckets, key)
+		}
+	}
+	l.swept = now
+}
@@ -0,0 +1,108 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func TestBucket(t *testing.T) {
+	start := time.Unix(0, 0)
+	b := NewBucket(Per(2, time.Second), start)
+
+	for i := range 2 {
+		if ok, _ := b.Take(start); !ok {
+			t.Fatalf("request %d refused within the burst", i+1)
+		}
+	}
+	ok, wait := b.Take(start)
+	if ok || wait != 500*time.Millisecond {
+		t.Fatalf("Take past the burst = %v, %v; want refused for 500ms", ok, wait)
+	}
+	if ok, _ := b.Take(start.Add(wait)); !ok {
+		t.Fatal("refused once the wait had passed")
+	}
+}
+
+func TestUnlimitedBucket(t *testing.T) {
+	b := NewBucket(Limit{}, time.Unix(0, 0))
+	for range 100 {
+		if ok, _ := b.Take(time.Unix(0, 0)); !ok {
+			t.Fatal("unlimited bucket refused a request")
+		}
+	}
+}
+
+func TestLimiterKeysAndSweep(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	l := New(Per(1, 10*time.Second), clock)
+
+	if ok, _ := l.Allow("a"); !ok {
+		t.Fatal("first request from a refused")
+	}
+	if ok, _ := l.Allow("a"); ok {
+		t.Fatal("second request from a allowed")
+	}
+	if ok, _ := l.Allow("b"); !ok {
+		t.Fatal("b was limited by a's bucket")
+	}
+
+	clock.advance(10 * time.Second)
+	if ok, _ := l.Allow("a"); !ok {
+		t.Fatal("a refused after refilling")
+	}
+
+	clock.advance(time.Minute)
+	l.Allow("c")
+	if _, ok := l.buckets["b"]; ok {
+		t.Error("refilled bucket of b was not swept")
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Limit
+		wantErr bool
+	}{
+		{in: "10/2s", want: Per(10, 2*time.Second)},
+		{in: " 5/1m ", want: Per(5, time.Minute)},
+		{in: "off", want: Limit{}},
+		{in: "10", wantErr: true},
+		{in: "0/1s", wantErr: true},
+		{in: "x/1s", wantErr: true},
+		{in: "3/0s", wantErr: true},
+		{in: "3/soon", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseLimit(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseLimit(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseLimit(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLimitStringRoundTrip(t *testing.T) {
+	for _, l := range []Limit{Per(10, 2*time.Second), Per(3, 30*time.Second), Per(5, time.Minute), {}} {
+		got, err := ParseLimit(l.String())
+		if err != nil || got != l {
+			t.Errorf("ParseLimit(%q) = %+v, %v; want %+v", l.String(), got, err, l)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package transcript
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JemJasonCorraggio/mao/internal/game"
+)
+
+type Format string
+
+const (
+	FormatJSON     Format = "json"
+	FormatCSV      Format = "csv"
+	FormatMarkdown Format = "md"
+)
+
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "", "json":
+		return FormatJSON, nil
+	case "csv":
+		return FormatCSV, nil
+	case "md", "markdown":
+		return FormatMarkdown, nil
+	}
+	return "", fmt.Errorf("unknown transcript format %q", s)
+}
+
+func (f Format) ContentType() string {
+	switch f {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatMarkdown:
+		return "text/markdown; charset=utf-8"
+	}
+	return "application/json"
+}
+
+type Transcript struct {
+	GameID   string   `json:"gameId"`
+	Status   string   `json:"status"`
+	Round    int      `json:"round"`
+	WinnerID string   `json:"winnerId,omitempty"`
+	Players  []Player `json:"players"`
+	Entries  []Entry  `json:"events"`
+}
+
+type Player struct {
+	ID        string   `json:"id"`
+	HandCount int      `json:"handCount"`
+	Hand      []string `json:"hand,omitempty"`
+}
+
+type Entry struct {
+	Seq         int64    `json:"seq"`
+	Time        string   `json:"time"`
+	Type        string   `json:"type"`
+	PlayerID    string   `json:"playerId,omitempty"`
+	ActionID    string   `json:"actionId,omitempty"`
+	ActionType  string   `json:"actionType,omitempty"`
+	Card        string   `json:"card,omitempty"`
+	Resolution  string   `json:"resolution,omitempty"`
+	Penalty     int      `json:"penalty,omitempty"`
+	Discarded   int      `json:"discarded,omitempty"`
+	Challengers []string `json:"challengers,omitempty"`
+	Overturn    bool     `json:"overturn,omitempty"`
+	Summary     string   `json:"summary"`
+}
+
+// Build assembles a transcript from a game's full event log. Hands are only
+// ever included once the session has ended, since between rounds an appeal
+// can still reopen the round, and not at all when redacted.
+func Build(g *game.Game, redactHands bool) (Transcript, error) {
+	events, err := g.Events()
+	if err != nil {
+		return Transcript{}, err
+	}
+
+	t := Transcript{
+		GameID:   g.ID,
+		Status:   string(g.Status),
+		Round:    g.Session.Round,
+		WinnerID: g.WinnerID,
+	}
+
+	showHands := !redactHands && g.Session.Ended
+	for _, p := range g.Players {
+		player := Player{ID: p.ID, HandCount: len(p.Hand)}
+		if showHands {
+			for _, c := range p.Hand {
+				player.Hand = append(player.Hand, cardName(c))
+			}
+		}
+		t.Players = append(t.Players, player)
+	}
+
+	for _, e := range events {
+		t.Entries = append(t.Entries, Entry{
+			Seq:         e.Seq,
+			Time:        time.Unix(e.Timestamp, 0).UTC().Format(time.RFC3339),
+			Type:        string(e.Type),
+			PlayerID:    e.PlayerID,
+			ActionID:    e.ActionID,
+			ActionType:  e.ActionType,
+			Card:        cardName(e.Card),
+			Resolution:  e.Resolution,
+			Penalty:     e.Penalty,
+			Discarded:   e.Discarded,
+			Challengers: e.Challengers,
+			Overturn:    e.Overturn,
+			Summary:     summarize(e),
+		})
+	}
+	return t, nil
+}
+
+func Render(w io.Writer, t Transcript, f Format) error {
+	switch f {
+	case FormatCSV:
+		return renderCSV(w, t)
+	case FormatMarkdown:
+		return renderMarkdown(w, t)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(t)
+}
+
+func renderCSV(w io.Writer, t Transcript) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{
+		"seq", "time", "type", "player", "action_id", "action_type", "card",
+		"resolution", "penalty", "discarded", "challengers", "overturn", "summary",
+	})
+	for _, e := range t.Entries {
+		cw.Write([]string{
+			strconv.FormatInt(e.Seq, 10),
+			e.Time,
+			e.Type,
+			e.PlayerID,
+			e.ActionID,
+			e.ActionType,
+			e.Card,
+			e.Resolution,
+			strconv.Itoa(e.Penalty),
+			strconv.Itoa(e.Discarded),
+			strings.Join(e.Challengers, ";"),
+			strconv.FormatBool(e.Overturn),
+			e.Summary,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func renderMarkdown(w io.Writer, t Transcript) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Mao game %s\n\n", t.GameID)
+	fmt.Fprintf(&b, "- Status: %s\n", t.Status)
+	fmt.Fprintf(&b, "- Round: %d\n", t.Round)
+	if t.WinnerID != "" {
+		fmt.Fprintf(&b, "- Winner: %s\n", t.WinnerID)
+	}
+
+	b.WriteString("\n## Players\n\n")
+	for _, p := range t.Players {
+		fmt.Fprintf(&b, "- **%s** — %d cards", p.ID, p.HandCount)
+		if len(p.Hand) > 0 {
+			fmt.Fprintf(&b, ": %s", strings.Join(p.Hand, ", "))
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n## Events\n\n")
+	for _, e := range t.Entries {
+		fmt.Fprintf(&b, "%d. `%s` %s\n", e.Seq, e.Time, e.Summary)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func cardName(c *game.Card) string {
+	if c == nil {
+		return ""
+	}
+	return c.Rank + " of " + c.Suit
+}
+
+func summarize(e game.Event) string {
+	switch e.Type {
+	case game.EventAction:
+		switch e.ActionType {
+		case "START_GAME":
+			return fmt.Sprintf("Game started with %s; %s turned up", strings.Join(e.Players, ", "), cardName(e.Card))
+		case "START_ROUND":
+			return fmt.Sprintf("New round dealt; %s turned up", cardName(e.Card))
+		}
+		if e.Resolution == string(game.ResolutionReject) {
+			if e.ActionType == string(game.ActionPlayCard) {
+				return fmt.Sprintf("%s tried to play %s (rejected)", e.PlayerID, cardName(e.Card))
+			}
+			return fmt.Sprintf("%s tried to draw a card (rejected)", e.PlayerID)
+		}
+		if e.ActionType == string(game.ActionPlayCard) {
+			return fmt.Sprintf("%s played %s", e.PlayerID, cardName(e.Card))
+		}
+		return fmt.Sprintf("%s drew a card", e.PlayerID)
+	case game.EventPenalty:
+		return fmt.Sprintf("%s penalized %d card(s)", e.PlayerID, e.Penalty)
+	case game.EventDiscard:
+		return fmt.Sprintf("%s discarded %d card(s) for a correct challenge", e.PlayerID, e.Discarded)
+	case game.EventResolution:
+		s := fmt.Sprintf("%s's %s resolved as %s", e.PlayerID, e.ActionType, e.Resolution)
+		if len(e.Challengers) > 0 {
+			s += fmt.Sprintf(" (challenged by %s)", strings.Join(e.Challengers, ", "))
+		}
+		return s
+	case game.EventKick:
+		return fmt.Sprintf("%s was removed from the game", e.PlayerID)
+	case game.EventRoundWon:
+		return fmt.Sprintf("%s won the round", e.PlayerID)
+	case game.EventSessionEnded:
+		return fmt.Sprintf("%s ended the session", e.PlayerID)
+	case game.EventRuleSubmitted:
+		return fmt.Sprintf("%s added a secret rule", e.PlayerID)
+	case game.EventAppealCalled:
+		return fmt.Sprintf("%s appealed the ruling", e.PlayerID)
+	case game.EventAppealVote:
+		if e.Overturn {
+			return fmt.Sprintf("%s voted to overturn", e.PlayerID)
+		}
+		return fmt.Sprintf("%s voted to uphold", e.PlayerID)
+	case game.EventAppealClosed:
+		if e.Overturn {
+			return "Appeal succeeded; the ruling was overturned"
+		}
+		return "Appeal failed; the ruling stands"
+	}
+	return string(e.Type)
+}
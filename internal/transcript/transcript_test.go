@@ -0,0 +1,148 @@
+package transcript
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+
+	"github.com/JemJasonCorraggio/mao/internal/game"
+)
+
+func TestRenderCSVColumns(t *testing.T) {
+	tr := Transcript{
+		GameID: "ABCD",
+		Entries: []Entry{{
+			Seq:        3,
+			Time:       "2024-01-01T00:00:00Z",
+			Type:       string(game.EventAction),
+			PlayerID:   "b",
+			ActionID:   "act-1",
+			ActionType: string(game.ActionPlayCard),
+			Card:       "7 of hearts",
+			Resolution: string(game.ResolutionReject),
+			Penalty:    2,
+			Summary:    "b tried to play 7 of hearts (rejected)",
+		}},
+	}
+
+	var buf bytes.Buffer
+	if err := Render(&buf, tr, FormatCSV); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want header and one row", len(records))
+	}
+
+	row := make(map[string]string)
+	for i, name := range records[0] {
+		row[name] = records[1][i]
+	}
+
+	want := map[string]string{
+		"seq":         "3",
+		"player":      "b",
+		"action_id":   "act-1",
+		"action_type": string(game.ActionPlayCard),
+		"card":        "7 of hearts",
+		"resolution":  string(game.ResolutionReject),
+		"penalty":     "2",
+		"overturn":    "false",
+	}
+	for col, v := range want {
+		if row[col] != v {
+			t.Errorf("column %s = %q, want %q", col, row[col], v)
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Format
+		wantErr bool
+	}{
+		{in: "", want: FormatJSON},
+		{in: "JSON", want: FormatJSON},
+		{in: "csv", want: FormatCSV},
+		{in: "markdown", want: FormatMarkdown},
+		{in: "md", want: FormatMarkdown},
+		{in: "pdf", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseFormat(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseFormat(%q) = %q, %v; want %q, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestBuildShowsHandsOnlyOnceSessionEnds(t *testing.T) {
+	reg := game.NewRegistry(game.DefaultConfig(), game.NewMemoryStore())
+	g, err := reg.CreateGame(&game.Player{ID: "a", Name: "a", IsAdmin: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reg.JoinGame(g.ID, &game.Player{ID: "b", Name: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.StartGame("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	handShown := func(redact bool) bool {
+		t.Helper()
+		tr, err := Build(g, redact)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range tr.Players {
+			if len(p.Hand) > 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	if handShown(false) {
+		t.Error("hands shown mid-round")
+	}
+
+	// b wins the round with their last card
+	b := g.Players[1]
+	b.Hand = b.Hand[:1]
+	a := &game.Action{
+		ID:           "win",
+		PlayerID:     "b",
+		Type:         game.ActionPlayCard,
+		Card:         b.Hand[0],
+		AcceptedBy:   map[string]bool{},
+		ChallengedBy: map[string]bool{},
+	}
+	if err := g.ProposeAction(a); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.ResolveAction("a", game.ResolutionAccept, 0); err != nil {
+		t.Fatal(err)
+	}
+	if g.Status != game.GameEnded || g.Session.Ended {
+		t.Fatalf("status %s, session ended %v; want between rounds", g.Status, g.Session.Ended)
+	}
+	if handShown(false) {
+		t.Error("hands shown between rounds")
+	}
+
+	if err := g.EndSession("a"); err != nil {
+		t.Fatal(err)
+	}
+	if !handShown(false) {
+		t.Error("hands hidden after the session ended")
+	}
+	if handShown(true) {
+		t.Error("hands shown in a redacted transcript")
+	}
+}
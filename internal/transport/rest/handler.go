@@ -0,0 +1,351 @@
+package rest
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/JemJasonCorraggio/mao/internal/game"
+	"github.com/JemJasonCorraggio/mao/internal/transcript"
+	"github.com/JemJasonCorraggio/mao/internal/transport/ws"
+)
+
+const maxSnapshotBytes = 10 << 20
+
+// Notifier pushes changes made over HTTP to players connected through
+// other transports.
+type Notifier interface {
+	GameChanged(gameID string)
+	SessionEnded(gameID string)
+	PlayerRemoved(gameID, playerID string)
+}
+
+// Guard applies the same rate limits to HTTP requests as to socket
+// messages, keyed by client IP.
+type Guard interface {
+	ClientIP(r *http.Request) string
+	Admit(ip, class string) error
+	GameCreated(ip, gameID string)
+}
+
+// Views projects a game for one player as the WebSocket transport sends
+// it, so both report the same state version.
+type Views interface {
+	ToPlayerGameState(g *game.Game, playerID string) ws.PlayerGameState
+}
+
+type Options struct {
+	// ImportToken is the operator secret that lets POST /api/games/import
+	// keep a snapshot's original code; empty leaves only resuming under a
+	// fresh code.
+	ImportToken string
+}
+
+type Handler struct {
+	options  Options
+	games    *game.Registry
+	notifier Notifier
+	guard    Guard
+	views    Views
+}
+
+func NewHandler(options Options, games *game.Registry, notifier Notifier, guard Guard, views Views) *Handler {
+	return &Handler{options: options, games: games, notifier: notifier, guard: guard, views: views}
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("write response failed: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, errorResponse{Error: msg})
+}
+
+// admit reports whether the request is within its limits, answering 429
+// with Retry-After when it is not.
+func (h *Handler) admit(w http.ResponseWriter, r *http.Request, class string) (string, bool) {
+	ip := h.guard.ClientIP(r)
+	if err := h.guard.Admit(ip, class); err != nil {
+		if wait := ws.RetryAfter(err); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		}
+		writeError(w, http.StatusTooManyRequests, err.Error())
+		return ip, false
+	}
+	return ip, true
+}
+
+// bearerToken is the token a request carries as "Authorization: Bearer".
+func bearerToken(r *http.Request) string {
+	const prefix = "Bearer "
+	auth := r.Header.Get("Authorization")
+	if len(auth) < len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return ""
+	}
+	return strings.TrimSpace(auth[len(prefix):])
+}
+
+// authenticate reports whether the request carries the seat token of
+// playerID, answering 401 when it does not.
+func authenticate(w http.ResponseWriter, r *http.Request, g *game.Game, playerID string) bool {
+	if err := g.Authenticate(playerID, bearerToken(r)); err != nil {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return false
+	}
+	return true
+}
+
+// History serves GET /api/games/{code}/history. Filters mirror the
+// GET_HISTORY WebSocket message: cursor, limit, playerId, type (repeatable),
+// since and until. Like that message it needs no seat: the log only holds
+// what was played face up, so anyone with the code may read it.
+func (h *Handler) History(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.admit(w, r, ws.ClassQuery); !ok {
+		return
+	}
+
+	g, err := h.games.GetGame(r.PathValue("code"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	q := r.URL.Query()
+	filter := ws.HistoryFilterDTO{
+		PlayerID: q.Get("playerId"),
+		Types:    q["type"],
+	}
+
+	cursor, err := intParam(q.Get("cursor"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid cursor")
+		return
+	}
+	limit, err := intParam(q.Get("limit"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid limit")
+		return
+	}
+	if filter.Since, err = intParam(q.Get("since")); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid since")
+		return
+	}
+	if filter.Until, err = intParam(q.Get("until")); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid until")
+		return
+	}
+
+	g.Lock()
+	page, err := g.History(filter.Query(cursor, int(limit)))
+	g.Unlock()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ws.ToHistoryPageDTO(page))
+}
+
+// Replay serves GET /api/games/{code}/replay. With ?step=N it returns the
+// single frame after N events, otherwise every frame of the game. It is
+// public, as History is, and keeps working after the game expires.
+func (h *Handler) Replay(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.admit(w, r, ws.ClassQuery); !ok {
+		return
+	}
+
+	code := r.PathValue("code")
+
+	if v := r.URL.Query().Get("step"); v != "" {
+		step, err := strconv.Atoi(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid step")
+			return
+		}
+
+		frame, err := h.games.ReplayStep(code, step)
+		if err != nil {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		writeJSON(w, http.StatusOK, ws.ToReplayFrameDTO(frame))
+		return
+	}
+
+	frames, err := h.games.Replay(code)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	dtos := make([]ws.ReplayFrameDTO, 0, len(frames))
+	for _, f := range frames {
+		dtos = append(dtos, ws.ToReplayFrameDTO(f))
+	}
+	writeJSON(w, http.StatusOK, dtos)
+}
+
+// Export serves GET /api/games/{code}/export?format=json|csv|md. Final
+// hands are included once the session has ended unless ?redact=true. It is
+// public, as History is.
+func (h *Handler) Export(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.admit(w, r, ws.ClassQuery); !ok {
+		return
+	}
+
+	g, err := h.games.GetGame(r.PathValue("code"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	format, err := transcript.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	redact, _ := strconv.ParseBool(r.URL.Query().Get("redact"))
+
+	g.Lock()
+	t, err := transcript.Build(g, redact)
+	g.Unlock()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="mao-%s.%s"`, g.ID, format))
+	if err := transcript.Render(w, t, format); err != nil {
+		log.Printf("export failed: %v", err)
+	}
+}
+
+// Snapshot serves GET /api/games/{code}/snapshot?playerId=. Only the admin
+// may download it since it contains every hand. Seat tokens and the RNG
+// state are left out, and it is sealed with a checksum.
+func (h *Handler) Snapshot(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.admit(w, r, ws.ClassQuery); !ok {
+		return
+	}
+
+	g, err := h.games.GetGame(r.PathValue("code"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	playerID := r.URL.Query().Get("playerId")
+	g.Lock()
+	defer g.Unlock()
+
+	if !authenticate(w, r, g, playerID) {
+		return
+	}
+	if playerID != g.AdminID {
+		writeError(w, http.StatusForbidden, "only admin can download a snapshot")
+		return
+	}
+
+	snapshot, err := g.Snapshot()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="mao-%s.snapshot.json"`, g.ID))
+	writeJSON(w, http.StatusOK, snapshot.Redacted().Sealed())
+}
+
+type importResponse struct {
+	GameID string `json:"gameId"`
+	// Tokens holds the new seat token of every player, for the importer to
+	// hand out so players can rejoin.
+	Tokens map[string]string `json:"tokens"`
+	// RNGReseeded is set when the snapshot carried no RNG state, as every
+	// downloaded snapshot does: the deck is dealt from a new seed, so cards
+	// drawn from here on differ from those the original game would have dealt.
+	RNGReseeded bool `json:"rngReseeded"`
+}
+
+// Import serves POST /api/games/import with a snapshot as the body. Anyone
+// holding a sealed snapshot, normally the admin who downloaded it, can
+// resume it as a new game under a fresh code. An operator sending the
+// configured import token as a bearer token instead keeps the original code,
+// to move games between servers.
+func (h *Handler) Import(w http.ResponseWriter, r *http.Request) {
+	ip, ok := h.admit(w, r, ws.ClassCreate)
+	if !ok {
+		return
+	}
+
+	operator := bearerToken(r) != ""
+	if operator {
+		if h.options.ImportToken == "" ||
+			subtle.ConstantTimeCompare([]byte(bearerToken(r)), []byte(h.options.ImportToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "invalid import token")
+			return
+		}
+	}
+
+	var snapshot game.Snapshot
+	body := http.MaxBytesReader(w, r.Body, maxSnapshotBytes)
+	if err := json.NewDecoder(body).Decode(&snapshot); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid snapshot: "+err.Error())
+		return
+	}
+
+	var g *game.Game
+	var err error
+	if operator {
+		g, err = h.games.ImportSnapshot(snapshot)
+	} else {
+		g, err = h.games.ResumeSnapshot(snapshot)
+	}
+	if errors.Is(err, game.ErrShuttingDown) || errors.Is(err, game.ErrNoGameCode) {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	h.guard.GameCreated(ip, g.ID)
+	resp := importResponse{
+		GameID:      g.ID,
+		Tokens:      make(map[string]string, len(g.Players)),
+		RNGReseeded: snapshot.RNG.Seed == 0,
+	}
+	g.Lock()
+	for _, p := range g.Players {
+		resp.Tokens[p.ID] = p.Token
+	}
+	g.Unlock()
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+func intParam(v string) (int64, error) {
+	if v == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(v, 10, 64)
+}
@@ -0,0 +1,269 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/JemJasonCorraggio/mao/internal/game"
+	"github.com/JemJasonCorraggio/mao/internal/transport/ws"
+)
+
+const maxRequestBytes = 64 << 10
+
+// GameInfo is what anyone may see about a game without joining it. Who is
+// seated is left out, since player ids are what actors name themselves by.
+type GameInfo struct {
+	ID          string         `json:"id"`
+	Status      string         `json:"status"`
+	PlayerCount int            `json:"playerCount"`
+	Round       int            `json:"round"`
+	Settings    ws.SettingsDTO `json:"settings"`
+}
+
+type JoinRequest struct {
+	Name string `json:"name"`
+}
+
+type JoinResponse struct {
+	PlayerID string             `json:"playerId"`
+	State    ws.PlayerGameState `json:"state"`
+}
+
+// ActorRequest is the body of every admin operation. PlayerID identifies
+// who is acting, and the request must carry their seat token.
+type ActorRequest struct {
+	PlayerID       string                `json:"playerId"`
+	TargetPlayerID string                `json:"targetPlayerId,omitempty"`
+	Resolution     game.ActionResolution `json:"resolution,omitempty"`
+	PenaltyCount   int                   `json:"penaltyCount,omitempty"`
+	Permission     game.Permission       `json:"permission,omitempty"`
+	Settings       *ws.SettingsDTO       `json:"settings,omitempty"`
+}
+
+func toGameInfo(g *game.Game) GameInfo {
+	return GameInfo{
+		ID:          g.ID,
+		Status:      string(g.Status),
+		PlayerCount: len(g.Players),
+		Round:       g.Session.Round,
+		Settings:    ws.ToSettingsDTO(g.Settings),
+	}
+}
+
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	body := http.MaxBytesReader(w, r.Body, maxRequestBytes)
+	if err := json.NewDecoder(body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// CreateGame serves POST /api/games.
+func (h *Handler) CreateGame(w http.ResponseWriter, r *http.Request) {
+	ip, ok := h.admit(w, r, ws.ClassCreate)
+	if !ok {
+		return
+	}
+
+	var req JoinRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+
+	player := &game.Player{ID: req.Name}
+	g, err := h.games.CreateGame(player)
+	if errors.Is(err, game.ErrNoGameCode) || errors.Is(err, game.ErrShuttingDown) {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	g.Lock()
+	state := h.views.ToPlayerGameState(g, player.ID)
+	g.Unlock()
+
+	h.guard.GameCreated(ip, g.ID)
+	writeJSON(w, http.StatusCreated, JoinResponse{
+		PlayerID: player.ID,
+		State:    state,
+	})
+}
+
+// JoinGame serves POST /api/games/{code}/join.
+func (h *Handler) JoinGame(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.admit(w, r, ws.ClassJoin); !ok {
+		return
+	}
+
+	var req JoinRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+
+	g, err := h.games.GetGame(r.PathValue("code"))
+	if err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	player := &game.Player{ID: req.Name}
+	g.Lock()
+	_, err = h.games.JoinGame(g.ID, player)
+	state := h.views.ToPlayerGameState(g, player.ID)
+	g.Unlock()
+	if err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	h.notifier.GameChanged(g.ID)
+	writeJSON(w, http.StatusOK, JoinResponse{
+		PlayerID: player.ID,
+		State:    state,
+	})
+}
+
+// GameInfo serves GET /api/games/{code}.
+func (h *Handler) GameInfo(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.admit(w, r, ws.ClassQuery); !ok {
+		return
+	}
+
+	g, err := h.games.GetGame(r.PathValue("code"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	g.Lock()
+	info := toGameInfo(g)
+	g.Unlock()
+
+	writeJSON(w, http.StatusOK, info)
+}
+
+// PlayerState serves GET /api/games/{code}/state?playerId=, the same
+// projection a player receives as GAME_STATE over the socket.
+func (h *Handler) PlayerState(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.admit(w, r, ws.ClassQuery); !ok {
+		return
+	}
+
+	g, err := h.games.GetGame(r.PathValue("code"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	playerID := r.URL.Query().Get("playerId")
+	g.Lock()
+	defer g.Unlock()
+
+	if !authenticate(w, r, g, playerID) {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.views.ToPlayerGameState(g, playerID))
+}
+
+// AdminAction serves POST /api/games/{code}/{op} for the privileged
+// operations. Permission checks are left to the game package.
+func (h *Handler) AdminAction(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.admit(w, r, ws.ClassAdmin); !ok {
+		return
+	}
+
+	g, err := h.games.GetGame(r.PathValue("code"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	var req ActorRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	op := r.PathValue("op")
+	g.Lock()
+	if !authenticate(w, r, g, req.PlayerID) {
+		g.Unlock()
+		return
+	}
+	err = runAdminOp(g, op, req)
+	info := toGameInfo(g)
+	g.Unlock()
+
+	switch {
+	case errors.Is(err, errUnknownOperation):
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, errSettingsRequired):
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	case err != nil:
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	// the notifier takes the game's lock itself
+	switch op {
+	case "kick":
+		h.notifier.PlayerRemoved(g.ID, req.TargetPlayerID)
+		h.notifier.GameChanged(g.ID)
+	case "end-session":
+		h.notifier.SessionEnded(g.ID)
+	default:
+		h.notifier.GameChanged(g.ID)
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+var (
+	errUnknownOperation = errors.New("unknown operation")
+	errSettingsRequired = errors.New("settings are required")
+)
+
+// runAdminOp applies an admin operation for the acting player. The caller
+// holds the game's lock.
+func runAdminOp(g *game.Game, op string, req ActorRequest) error {
+	switch op {
+	case "start":
+		return g.StartGame(req.PlayerID)
+	case "resolve":
+		return g.ResolveAction(req.PlayerID, req.Resolution, req.PenaltyCount)
+	case "penalize":
+		return g.PenalizePlayer(req.PlayerID, req.TargetPlayerID, req.PenaltyCount)
+	case "kick":
+		return g.KickPlayer(req.PlayerID, req.TargetPlayerID)
+	case "grant":
+		return g.GrantPermission(req.PlayerID, req.TargetPlayerID, req.Permission)
+	case "revoke":
+		return g.RevokePermission(req.PlayerID, req.TargetPlayerID, req.Permission)
+	case "settings":
+		if req.Settings == nil {
+			return errSettingsRequired
+		}
+		return g.UpdateSettings(req.PlayerID, req.Settings.Settings())
+	case "next-round":
+		return g.StartNextRound(req.PlayerID)
+	case "end-session":
+		return g.EndSession(req.PlayerID)
+	}
+	return errUnknownOperation
+}
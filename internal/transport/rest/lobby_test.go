@@ -0,0 +1,273 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JemJasonCorraggio/mao/internal/game"
+	"github.com/JemJasonCorraggio/mao/internal/ratelimit"
+	"github.com/JemJasonCorraggio/mao/internal/transport/ws"
+)
+
+type recordingNotifier struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (n *recordingNotifier) record(call string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.calls = append(n.calls, call)
+}
+
+func (n *recordingNotifier) GameChanged(gameID string)             { n.record("changed") }
+func (n *recordingNotifier) SessionEnded(gameID string)            { n.record("ended") }
+func (n *recordingNotifier) PlayerRemoved(gameID, playerID string) { n.record("removed " + playerID) }
+
+type testServer struct {
+	*httptest.Server
+	notifier *recordingNotifier
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	games := game.NewRegistry(game.DefaultConfig(), game.NewMemoryStore())
+	notifier := &recordingNotifier{}
+	wsHandler := ws.NewHandler(ws.DefaultOptions(), games)
+	h := NewHandler(Options{ImportToken: "operator"}, games, notifier, wsHandler, wsHandler)
+	return &testServer{Server: serve(t, h), notifier: notifier}
+}
+
+// serve routes the REST endpoints to h on a test server.
+func serve(t *testing.T, h *Handler) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/games", h.CreateGame)
+	mux.HandleFunc("GET /api/games/{code}", h.GameInfo)
+	mux.HandleFunc("POST /api/games/{code}/join", h.JoinGame)
+	mux.HandleFunc("GET /api/games/{code}/state", h.PlayerState)
+	mux.HandleFunc("POST /api/games/{code}/{op}", h.AdminAction)
+	mux.HandleFunc("GET /api/games/{code}/history", h.History)
+	mux.HandleFunc("GET /api/games/{code}/export", h.Export)
+	mux.HandleFunc("GET /api/games/{code}/snapshot", h.Snapshot)
+	mux.HandleFunc("POST /api/games/import", h.Import)
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func (s *testServer) do(t *testing.T, method, path, token string, body interface{}) *http.Response {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, s.URL+path, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func decode(t *testing.T, resp *http.Response, v interface{}) {
+	t.Helper()
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// seat creates a game for admin and seats the others, returning the code
+// and every seat token.
+func (s *testServer) seat(t *testing.T, admin string, others ...string) (string, map[string]string) {
+	t.Helper()
+
+	var created JoinResponse
+	resp := s.do(t, "POST", "/api/games", "", JoinRequest{Name: admin})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create: %s", resp.Status)
+	}
+	decode(t, resp, &created)
+
+	code := created.State.ID
+	tokens := map[string]string{admin: created.State.SeatToken}
+	for _, name := range others {
+		var joined JoinResponse
+		resp := s.do(t, "POST", "/api/games/"+code+"/join", "", JoinRequest{Name: name})
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("join %s: %s", name, resp.Status)
+		}
+		decode(t, resp, &joined)
+		tokens[name] = joined.State.SeatToken
+	}
+	return code, tokens
+}
+
+func TestGameInfoHidesPlayers(t *testing.T) {
+	s := newTestServer(t)
+	code, _ := s.seat(t, "ann", "bob")
+
+	resp := s.do(t, "GET", "/api/games/"+code, "", nil)
+	var raw map[string]interface{}
+	decode(t, resp, &raw)
+
+	for _, field := range []string{"adminId", "players"} {
+		if _, ok := raw[field]; ok {
+			t.Errorf("public game info has %q", field)
+		}
+	}
+	if raw["playerCount"] != float64(2) {
+		t.Errorf("playerCount = %v, want 2", raw["playerCount"])
+	}
+}
+
+func TestActorEndpointsRequireSeatToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   interface{}
+		as     string
+		want   int
+	}{
+		{name: "admin op without token", method: "POST", path: "/start", body: ActorRequest{PlayerID: "ann"}, want: http.StatusUnauthorized},
+		{name: "admin op with another seat's token", method: "POST", path: "/start", body: ActorRequest{PlayerID: "ann"}, as: "bob", want: http.StatusUnauthorized},
+		{name: "admin op with own token", method: "POST", path: "/start", body: ActorRequest{PlayerID: "ann"}, as: "ann", want: http.StatusOK},
+		{name: "state without token", method: "GET", path: "/state?playerId=bob", want: http.StatusUnauthorized},
+		{name: "state with another seat's token", method: "GET", path: "/state?playerId=bob", as: "ann", want: http.StatusUnauthorized},
+		{name: "state with own token", method: "GET", path: "/state?playerId=bob", as: "bob", want: http.StatusOK},
+		{name: "snapshot by a player", method: "GET", path: "/snapshot?playerId=bob", as: "bob", want: http.StatusForbidden},
+		{name: "snapshot by the admin", method: "GET", path: "/snapshot?playerId=ann", as: "ann", want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			code, tokens := s.seat(t, "ann", "bob")
+
+			resp := s.do(t, tt.method, "/api/games/"+code+tt.path, tokens[tt.as], tt.body)
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestEndSessionNotifiesSessionEnded(t *testing.T) {
+	s := newTestServer(t)
+	code, tokens := s.seat(t, "ann", "bob")
+
+	for _, op := range []string{"start", "end-session"} {
+		resp := s.do(t, "POST", "/api/games/"+code+"/"+op, tokens["ann"], ActorRequest{PlayerID: "ann"})
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: %s", op, resp.Status)
+		}
+	}
+
+	s.notifier.mu.Lock()
+	defer s.notifier.mu.Unlock()
+	if got := strings.Join(s.notifier.calls, ","); got != "changed,changed,ended" {
+		t.Errorf("notifications = %s, want changed,changed,ended", got)
+	}
+}
+
+func TestImport(t *testing.T) {
+	source := newTestServer(t)
+	code, tokens := source.seat(t, "ann", "bob")
+
+	var snapshot game.Snapshot
+	decode(t, source.do(t, "GET", "/api/games/"+code+"/snapshot?playerId=ann", tokens["ann"], nil), &snapshot)
+	if snapshot.RNG != (game.SnapshotRNG{}) {
+		t.Errorf("exported snapshot carries the RNG state %+v", snapshot.RNG)
+	}
+	for _, p := range snapshot.Game.Players {
+		if p.Token != "" {
+			t.Errorf("exported snapshot carries %s's seat token", p.ID)
+		}
+	}
+
+	target := newTestServer(t)
+	if resp := target.do(t, "POST", "/api/games/import", "guess", snapshot); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("import with a wrong operator token: %s", resp.Status)
+	}
+
+	tampered := snapshot
+	tampered.Game.AdminID = "bob"
+	if resp := target.do(t, "POST", "/api/games/import", "", tampered); resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("resume of an edited snapshot: %s", resp.Status)
+	}
+
+	// without a token the admin resumes it as a new game, even on the server
+	// where the original is still live
+	for _, s := range []*testServer{source, target} {
+		resp := s.do(t, "POST", "/api/games/import", "", snapshot)
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("resume: %s", resp.Status)
+		}
+		var resumed importResponse
+		decode(t, resp, &resumed)
+
+		if resumed.GameID == "" || resumed.GameID == code || len(resumed.Tokens) != 2 || !resumed.RNGReseeded {
+			t.Fatalf("resume response = %+v", resumed)
+		}
+		if resp := s.do(t, "GET", "/api/games/"+resumed.GameID+"/state?playerId=ann", resumed.Tokens["ann"], nil); resp.StatusCode != http.StatusOK {
+			t.Errorf("state with the issued token: %s", resp.Status)
+		}
+	}
+
+	resp := target.do(t, "POST", "/api/games/import", "operator", snapshot)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("import: %s", resp.Status)
+	}
+	var imported importResponse
+	decode(t, resp, &imported)
+
+	if imported.GameID != code || len(imported.Tokens) != 2 {
+		t.Fatalf("import response = %+v", imported)
+	}
+	if imported.Tokens["bob"] == tokens["bob"] {
+		t.Error("import reused a seat token")
+	}
+	if resp := target.do(t, "GET", "/api/games/"+code+"/state?playerId=bob", imported.Tokens["bob"], nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("state with the issued token: %s", resp.Status)
+	}
+}
+
+func TestQueriesAreRateLimited(t *testing.T) {
+	games := game.NewRegistry(game.DefaultConfig(), game.NewMemoryStore())
+	options := ws.DefaultOptions()
+	options.IPLimits = map[string]ratelimit.Limit{ws.ClassQuery: ratelimit.Per(1, time.Minute)}
+	wsHandler := ws.NewHandler(options, games)
+	s := &testServer{Server: serve(t, NewHandler(Options{}, games, &recordingNotifier{}, wsHandler, wsHandler))}
+	code, tokens := s.seat(t, "ann")
+
+	if resp := s.do(t, "GET", "/api/games/"+code, "", nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("first query: %s", resp.Status)
+	}
+	for _, path := range []string{"", "/state?playerId=ann", "/history", "/export", "/snapshot?playerId=ann"} {
+		resp := s.do(t, "GET", "/api/games/"+code+path, tokens["ann"], nil)
+		if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+			t.Errorf("GET %s = %s, want 429 with Retry-After", path, resp.Status)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/JemJasonCorraggio/mao/internal/game"
+	"github.com/JemJasonCorraggio/mao/internal/transport/ws"
+)
+
+// discardConn is a socket whose messages nobody reads.
+type discardConn struct{}
+
+func (discardConn) Write(ws.ServerMessage) error { return nil }
+func (discardConn) Close() error                 { return nil }
+
+// TestTransportsShareGameLock drives one game from the socket handler and
+// the REST handler at once. Run it with -race.
+func TestTransportsShareGameLock(t *testing.T) {
+	games := game.NewRegistry(game.DefaultConfig(), game.NewMemoryStore())
+	options := ws.DefaultOptions()
+	options.ConnLimits = nil
+	options.IPLimits = nil
+	wsHandler := ws.NewHandler(options, games)
+	s := &testServer{Server: serve(t, NewHandler(Options{}, games, wsHandler, wsHandler, wsHandler))}
+
+	code, tokens := s.seat(t, "ann", "bob", "cam")
+	if resp := s.do(t, "POST", "/api/games/"+code+"/start", tokens["ann"], ActorRequest{PlayerID: "ann"}); resp.StatusCode != http.StatusOK {
+		t.Fatalf("start: %s", resp.Status)
+	}
+
+	send := func(c *ws.Client, msg map[string]string) {
+		b, err := json.Marshal(msg)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if err := wsHandler.Dispatch(c, b); err != nil {
+			t.Error(err)
+		}
+	}
+
+	var sockets []*ws.Client
+	for _, name := range []string{"bob", "cam"} {
+		c := wsHandler.Attach(discardConn{}, "192.0.2.1")
+		t.Cleanup(func() { wsHandler.Detach(c) })
+		send(c, map[string]string{"type": "JOIN_GAME", "gameId": code, "name": name, "token": tokens[name]})
+		sockets = append(sockets, c)
+	}
+
+	const rounds = 20
+	var wg sync.WaitGroup
+	for _, c := range sockets {
+		wg.Add(1)
+		go func(c *ws.Client) {
+			defer wg.Done()
+			for range rounds {
+				send(c, map[string]string{"type": "PROPOSE_DRAW", "gameId": code})
+				send(c, map[string]string{"type": "ACCEPT_ACTION", "gameId": code})
+				send(c, map[string]string{"type": "RESYNC"})
+			}
+		}(c)
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := range rounds {
+			resolution := game.ResolutionAccept
+			if i%2 == 1 {
+				resolution = game.ResolutionReject
+			}
+			s.do(t, "POST", "/api/games/"+code+"/resolve", tokens["ann"], ActorRequest{PlayerID: "ann", Resolution: resolution})
+			s.do(t, "POST", "/api/games/"+code+"/penalize", tokens["ann"], ActorRequest{PlayerID: "ann", TargetPlayerID: "bob", PenaltyCount: 1})
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range rounds {
+			s.do(t, "GET", "/api/games/"+code, "", nil)
+			s.do(t, "GET", fmt.Sprintf("/api/games/%s/state?playerId=cam", code), tokens["cam"], nil)
+			s.do(t, "GET", "/api/games/"+code+"/history", "", nil)
+			s.do(t, "GET", "/api/games/"+code+"/export", "", nil)
+			games.CountGames()
+		}
+	}()
+
+	wg.Wait()
+
+	var state ws.PlayerGameState
+	decode(t, s.do(t, "GET", "/api/games/"+code+"/state?playerId=ann", tokens["ann"], nil), &state)
+	if state.ID != code {
+		t.Errorf("state.ID = %q, want %q", state.ID, code)
+	}
+}
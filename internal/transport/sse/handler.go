@@ -0,0 +1,208 @@
+package sse
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/JemJasonCorraggio/mao/internal/transport/ws"
+)
+
+const (
+	keepAliveInterval = 15 * time.Second
+	sendBuffer        = 64
+)
+
+var (
+	errClosed = errors.New("stream closed")
+	errSlow   = errors.New("stream is not keeping up")
+)
+
+// Dispatcher is the transport-agnostic side of the WebSocket handler: it
+// owns the client registry and applies client messages.
+type Dispatcher interface {
+	ClientIP(r *http.Request) string
+	Attach(conn ws.Conn, ip string) *ws.Client
+	Detach(c *ws.Client)
+	Dispatch(c *ws.Client, raw []byte) error
+}
+
+// Handler streams server messages as Server-Sent Events and accepts client
+// messages through HTTP POST, for networks that break WebSockets. Messages
+// have the same shape as on the WebSocket.
+type Handler struct {
+	dispatcher      Dispatcher
+	maxMessageBytes int64
+
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+func NewHandler(dispatcher Dispatcher, maxMessageBytes int64) *Handler {
+	return &Handler{
+		dispatcher:      dispatcher,
+		maxMessageBytes: maxMessageBytes,
+		sessions:        make(map[string]*session),
+	}
+}
+
+// session is one open event stream. It is the ws.Conn of its client, so
+// broadcasts reach it the same way they reach a socket.
+type session struct {
+	id     string
+	client *ws.Client
+
+	out       chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// dispatchMu keeps messages from concurrent POSTs in order, as a
+	// socket's read loop would.
+	dispatchMu sync.Mutex
+}
+
+func (s *session) Write(msg ws.ServerMessage) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-s.done:
+		return errClosed
+	default:
+	}
+
+	// a client that lets the buffer fill would miss messages, so it is
+	// dropped and reconnects for a fresh state instead
+	select {
+	case s.out <- b:
+		return nil
+	default:
+		s.Close()
+		return errSlow
+	}
+}
+
+func (s *session) Close() error {
+	s.closeOnce.Do(func() { close(s.done) })
+	return nil
+}
+
+type sessionEvent struct {
+	SessionID string `json:"sessionId"`
+}
+
+// Stream serves GET /sse. The first event, named "session", carries the id
+// to POST messages to; every later event is a ServerMessage.
+func (h *Handler) Stream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	id, err := newSessionID()
+	if err != nil {
+		log.Printf("cannot create sse session: %v", err)
+		http.Error(w, "cannot create session", http.StatusInternalServerError)
+		return
+	}
+
+	s := &session{
+		id:   id,
+		out:  make(chan []byte, sendBuffer),
+		done: make(chan struct{}),
+	}
+	s.client = h.dispatcher.Attach(s, h.dispatcher.ClientIP(r))
+
+	h.mu.Lock()
+	h.sessions[id] = s
+	h.mu.Unlock()
+
+	defer func() {
+		h.mu.Lock()
+		delete(h.sessions, id)
+		h.mu.Unlock()
+		h.dispatcher.Detach(s.client)
+	}()
+
+	log.Printf("sse connected: %s", r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	hello, _ := json.Marshal(sessionEvent{SessionID: id})
+	if _, err := fmt.Fprintf(w, "event: session\ndata: %s\n\n", hello); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.done:
+			return
+		case msg := <-s.out:
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", msg); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-ticker.C:
+			if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// Send serves POST /sse/{session}. The body is one ClientMessage, exactly
+// as it would be sent over the WebSocket.
+func (h *Handler) Send(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	s, ok := h.sessions[r.PathValue("session")]
+	h.mu.Unlock()
+	if !ok {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxMessageBytes))
+	if err != nil {
+		http.Error(w, "invalid message", http.StatusBadRequest)
+		return
+	}
+
+	s.dispatchMu.Lock()
+	err = h.dispatcher.Dispatch(s.client, body)
+	s.dispatchMu.Unlock()
+	if err != nil {
+		s.Close()
+		http.Error(w, "session closed", http.StatusGone)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,257 @@
+package sse
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JemJasonCorraggio/mao/internal/game"
+	"github.com/JemJasonCorraggio/mao/internal/transport/ws"
+)
+
+func newTestHandler(t *testing.T) *Handler {
+	t.Helper()
+
+	games := game.NewRegistry(game.DefaultConfig(), game.NewMemoryStore())
+	dispatcher := ws.NewHandler(ws.DefaultOptions(), games)
+	return NewHandler(dispatcher, dispatcher.MaxMessageBytes())
+}
+
+func newTestServer(t *testing.T) (*Handler, *httptest.Server) {
+	t.Helper()
+
+	h := newTestHandler(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /sse", h.Stream)
+	mux.HandleFunc("POST /sse/{session}", h.Send)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return h, server
+}
+
+// stream is the client end of an open event stream.
+type stream struct {
+	id     string
+	body   *bufio.Reader
+	closer func() error
+}
+
+func openStream(t *testing.T, server *httptest.Server) *stream {
+	t.Helper()
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(server.URL + "/sse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content type %q, want text/event-stream", ct)
+	}
+
+	s := &stream{body: bufio.NewReader(resp.Body), closer: resp.Body.Close}
+	name, data := s.event(t)
+	var hello sessionEvent
+	if err := json.Unmarshal(data, &hello); name != "session" || err != nil || hello.SessionID == "" {
+		t.Fatalf("first event %q %s, want a session", name, data)
+	}
+	s.id = hello.SessionID
+	return s
+}
+
+// event reads the next event, skipping keep-alive comments.
+func (s *stream) event(t *testing.T) (name string, data []byte) {
+	t.Helper()
+
+	for {
+		line, err := s.body.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && data != nil:
+			return name, data
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = []byte(strings.TrimPrefix(line, "data: "))
+		}
+	}
+}
+
+// next returns the payload of the next server message of type typ.
+func (s *stream) next(t *testing.T, typ string) json.RawMessage {
+	t.Helper()
+
+	for {
+		_, data := s.event(t)
+		var msg struct {
+			Type    string          `json:"type"`
+			Payload json.RawMessage `json:"payload"`
+		}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatalf("invalid event %s: %v", data, err)
+		}
+		if msg.Type == typ {
+			return msg.Payload
+		}
+	}
+}
+
+func post(t *testing.T, server *httptest.Server, id string, msg map[string]interface{}) int {
+	t.Helper()
+
+	b, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Post(server.URL+"/sse/"+id, "application/json", bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestRoundTrip(t *testing.T) {
+	_, server := newTestServer(t)
+	s := openStream(t, server)
+
+	hello := map[string]interface{}{"type": "HELLO", "protocolVersion": ws.ProtocolVersion, "capabilities": []string{ws.CapAcks}}
+	if status := post(t, server, s.id, hello); status != http.StatusAccepted {
+		t.Fatalf("HELLO answered %d, want %d", status, http.StatusAccepted)
+	}
+	var welcome ws.WelcomeDTO
+	if err := json.Unmarshal(s.next(t, "WELCOME"), &welcome); err != nil {
+		t.Fatal(err)
+	}
+	if len(welcome.Capabilities) != 1 || welcome.Capabilities[0] != ws.CapAcks {
+		t.Errorf("welcome capabilities %v, want acks", welcome.Capabilities)
+	}
+
+	create := map[string]interface{}{"type": "CREATE_GAME", "name": "ann", "requestId": "1"}
+	if status := post(t, server, s.id, create); status != http.StatusAccepted {
+		t.Fatalf("CREATE_GAME answered %d, want %d", status, http.StatusAccepted)
+	}
+	var state ws.PlayerGameState
+	if err := json.Unmarshal(s.next(t, "GAME_STATE"), &state); err != nil {
+		t.Fatal(err)
+	}
+	if state.PlayerID != "ann" || state.AdminID != "ann" {
+		t.Errorf("state is for %q with admin %q, want ann", state.PlayerID, state.AdminID)
+	}
+	var ack ws.AckDTO
+	if err := json.Unmarshal(s.next(t, "ACK"), &ack); err != nil {
+		t.Fatal(err)
+	}
+	if ack.RequestID != "1" {
+		t.Errorf("ACK for %q, want 1", ack.RequestID)
+	}
+}
+
+func TestSendToUnknownSession(t *testing.T) {
+	h, server := newTestServer(t)
+	ping := map[string]interface{}{"type": "PING"}
+
+	if status := post(t, server, "0123456789abcdef", ping); status != http.StatusNotFound {
+		t.Errorf("unknown session answered %d, want %d", status, http.StatusNotFound)
+	}
+
+	// a session ends once its stream is closed
+	s := openStream(t, server)
+	if status := post(t, server, s.id, ping); status != http.StatusAccepted {
+		t.Fatalf("open session answered %d, want %d", status, http.StatusAccepted)
+	}
+	s.closer()
+	waitForSessions(t, h, 0)
+	if status := post(t, server, s.id, ping); status != http.StatusNotFound {
+		t.Errorf("closed session answered %d, want %d", status, http.StatusNotFound)
+	}
+}
+
+func waitForSessions(t *testing.T, h *Handler, want int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		h.mu.Lock()
+		n := len(h.sessions)
+		h.mu.Unlock()
+		if n == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d sessions open, want %d", n, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// stalledWriter accepts the session event, then blocks every write until
+// released, like a client that has stopped reading.
+type stalledWriter struct {
+	header  http.Header
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (w *stalledWriter) Header() http.Header { return w.header }
+func (w *stalledWriter) WriteHeader(int)     {}
+func (w *stalledWriter) Flush()              {}
+
+func (w *stalledWriter) Write(b []byte) (int, error) {
+	if bytes.HasPrefix(b, []byte("event: session")) {
+		w.once.Do(func() { close(w.started) })
+		return len(b), nil
+	}
+	<-w.release
+	return len(b), nil
+}
+
+func TestSlowStreamIsDropped(t *testing.T) {
+	h := newTestHandler(t)
+	w := &stalledWriter{header: make(http.Header), started: make(chan struct{}), release: make(chan struct{})}
+	done := make(chan struct{})
+	go func() {
+		h.Stream(w, httptest.NewRequest(http.MethodGet, "/sse", nil))
+		close(done)
+	}()
+	<-w.started
+
+	h.mu.Lock()
+	var s *session
+	for _, open := range h.sessions {
+		s = open
+	}
+	h.mu.Unlock()
+
+	// one message is stuck in the writer; the buffer holds the rest
+	msg := ws.ServerMessage{Type: "PONG"}
+	var err error
+	for i := 0; i <= sendBuffer+1 && err == nil; i++ {
+		err = s.Write(msg)
+	}
+	if !errors.Is(err, errSlow) {
+		t.Fatalf("got %v, want %v", err, errSlow)
+	}
+	if err := s.Write(msg); !errors.Is(err, errClosed) {
+		t.Errorf("write after drop got %v, want %v", err, errClosed)
+	}
+
+	close(w.release)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream still open after falling behind")
+	}
+	waitForSessions(t, h, 0)
+}
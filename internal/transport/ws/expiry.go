@@ -0,0 +1,55 @@
+package ws
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/JemJasonCorraggio/mao/internal/game"
+)
+
+// GameExpiredDTO tells players still connected to a game that it was
+// removed for inactivity; its code may be given to a new game.
+type GameExpiredDTO struct {
+	GameID string `json:"gameId"`
+}
+
+// RunJanitor expires abandoned games, and drops the histories of games
+// expired long enough ago, every interval until ctx is done.
+func (h *Handler) RunJanitor(ctx context.Context, policy game.ExpiryPolicy, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, g := range h.games.ExpireGames(policy, now) {
+				g.Lock()
+				log.Printf("game %s expired (%s)", g.ID, g.Status)
+				g.Unlock()
+				h.gameExpired(g.ID)
+			}
+			for _, id := range h.games.PurgeHistories(now) {
+				log.Printf("game %s: history removed", id)
+			}
+		}
+	}
+}
+
+func (h *Handler) gameExpired(gameID string) {
+	for _, c := range h.gameClients(gameID) {
+		if err := c.Send(ServerMessage{Type: "GAME_EXPIRED", Payload: GameExpiredDTO{GameID: gameID}}); err != nil {
+			log.Printf("expiry notice failed to %s: %v", c.PlayerID, err)
+		}
+		c.bind("", "")
+		c.forgetState()
+	}
+
+	h.versionsMu.Lock()
+	delete(h.versions, gameID)
+	h.versionsMu.Unlock()
+
+	h.limits.gameRemoved(gameID)
+}
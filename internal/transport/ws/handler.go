@@ -1,27 +1,127 @@
 package ws
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/JemJasonCorraggio/mao/internal/msgpack"
 	"github.com/JemJasonCorraggio/mao/internal/game"
+	"github.com/JemJasonCorraggio/mao/internal/ratelimit"
 )
 
+// Conn is the transport a client is attached through. WebSockets are the
+// default; other transports only need to deliver server messages in order.
+type Conn interface {
+	Write(msg ServerMessage) error
+	Close() error
+}
+
+// Subprotocols a WebSocket client may offer. Without one, messages are
+// JSON text frames.
+const (
+	SubprotocolJSON    = "mao.json"
+	SubprotocolMsgPack = "mao.msgpack"
+)
+
+type wsConn struct {
+	conn      *websocket.Conn
+	writeWait time.Duration
+}
+
+func (w wsConn) Write(msg ServerMessage) error {
+	w.conn.SetWriteDeadline(time.Now().Add(w.writeWait))
+	if w.conn.Subprotocol() != SubprotocolMsgPack {
+		return w.conn.WriteJSON(msg)
+	}
+
+	b, err := msgpack.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return w.conn.WriteMessage(websocket.BinaryMessage, b)
+}
+
+func (w wsConn) Close() error {
+	return w.conn.Close()
+}
+
 type Client struct {
-	Conn     *websocket.Conn
+	Conn     Conn
 	GameID   string
 	PlayerID string
+
+	seatMu   sync.RWMutex
+	writeMu  sync.Mutex
+	stateMu  sync.Mutex
+	sent     *PlayerGameState
+	caps     map[string]bool
+	latency  latency
+	ip       string
+	buckets  map[string]*ratelimit.Bucket
+	ctx      context.Context
+	cancel   context.CancelFunc
+	replay   replayRunner
+}
+
+// Send serializes writes to the connection; broadcasts and replay streams
+// may write from other goroutines than the connection's read loop.
+func (c *Client) Send(msg ServerMessage) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	return c.Conn.Write(msg)
+}
+
+func (c *Client) bind(gameID, playerID string) {
+	c.seatMu.Lock()
+	defer c.seatMu.Unlock()
+
+	c.GameID = gameID
+	c.PlayerID = playerID
+}
+
+// seat is the game and player the client is bound to; both are empty
+// until it joins.
+func (c *Client) seat() (gameID, playerID string) {
+	c.seatMu.RLock()
+	defer c.seatMu.RUnlock()
+
+	return c.GameID, c.PlayerID
 }
 
-var clients = make(map[*websocket.Conn]*Client)
+// gameClients returns the clients attached to a game at the time of the
+// call, so sends happen without holding the registry lock.
+func (h *Handler) gameClients(gameID string) []*Client {
+	h.clientsMu.RLock()
+	defer h.clientsMu.RUnlock()
+
+	var out []*Client
+	for c := range h.clients {
+		if id, _ := c.seat(); id == gameID {
+			out = append(out, c)
+		}
+	}
+	return out
+}
 
 type ClientMessage struct {
 	Type   		string `json:"type"`
 	GameID  	string `json:"gameId,omitempty"`
 	PlayerID 	string `json:"playerId,omitempty"`
 	Name     	string `json:"name,omitempty"`
+	RequestID 	string `json:"requestId,omitempty"`
+	// Token is the seat token a JOIN_GAME for a seat already held must
+	// present to take it back.
+	Token     	string `json:"token,omitempty"`
 }
 
 type ServerMessage struct {
@@ -38,21 +138,85 @@ type GameState struct {
 
 type PlayerGameState struct {
   ID        	string    `json:"id"`
-  Status    	string    `json:"status"`
+  Status    	string    `json:"status" ts:"GameStatus"`
   AdminID   	string    `json:"adminId"`
 	Players   []PlayerInfo `json:"players"`
   Hand      	[]CardDTO `json:"hand"`
   PlayerID  	string    `json:"playerId"`
+  SeatToken 	string    `json:"seatToken,omitempty"`
   CurrentAction *ActionDTO `json:"currentAction,omitempty"`
   TopCard    	*CardDTO   `json:"topCard,omitempty"`
   LastAction 	*ActionDTO `json:"lastAction,omitempty"`
+  LastRejectedAction *ActionDTO `json:"lastRejectedAction,omitempty"`
   WinnerID      string     `json:"winnerId,omitempty"`
   RecentEvents  []EventDTO `json:"recentEvents,omitempty"`
+  Settings      SettingsDTO `json:"settings"`
+  Appeal        *AppealDTO  `json:"appeal,omitempty"`
+  Rulebook      []RuleDTO   `json:"rulebook,omitempty"`
+  Round           int              `json:"round"`
+  RoundResults    []RoundResultDTO `json:"roundResults,omitempty"`
+  PendingRuleFrom string           `json:"pendingRuleFrom,omitempty"`
+  Standings       []StandingDTO    `json:"standings"`
+  SessionEnded    bool             `json:"sessionEnded,omitempty"`
+  Version         int64            `json:"version"`
+}
+
+type StandingDTO struct {
+	PlayerID             string `json:"playerId"`
+	RoundWins            int    `json:"roundWins"`
+	PenaltyCards         int    `json:"penaltyCards"`
+	SuccessfulChallenges int    `json:"successfulChallenges"`
+	RejectedPlays        int    `json:"rejectedPlays"`
+}
+
+type SessionSummary struct {
+	GameID       string           `json:"gameId"`
+	Rounds       []RoundResultDTO `json:"rounds"`
+	Standings    []StandingDTO    `json:"standings"`
+}
+
+type RoundResultDTO struct {
+	Round    int    `json:"round"`
+	WinnerID string `json:"winnerId"`
+	EndedAt  int64  `json:"endedAt"`
+}
+
+type RuleDTO struct {
+	ID        string `json:"id"`
+	Text      string `json:"text"`
+	CreatedAt int64  `json:"createdAt"`
+	AddedBy   string `json:"addedBy"`
+}
+
+type SettingsDTO struct {
+	AppealsEnabled      bool    `json:"appealsEnabled"`
+	AppealWindowSeconds int     `json:"appealWindowSeconds"`
+	AppealMajority      float64 `json:"appealMajority"`
+	RevealRulebookOnEnd bool    `json:"revealRulebookOnEnd"`
+	ChallengePolicy     ChallengePolicyDTO `json:"challengePolicy"`
+}
+
+type ChallengePolicyDTO struct {
+	WrongChallengerPenalty   int `json:"wrongChallengerPenalty"`
+	WrongAcceptorPenalty     int `json:"wrongAcceptorPenalty"`
+	CorrectChallengerDiscard int `json:"correctChallengerDiscard"`
+}
+
+type AppealDTO struct {
+	ActionID   string   `json:"actionId"`
+	CalledBy   string   `json:"calledBy"`
+	Deadline   int64    `json:"deadline"`
+	Required   int      `json:"required"`
+	OverturnBy []string `json:"overturnBy"`
+	UpholdBy   []string `json:"upholdBy"`
 }
 
 type PlayerInfo struct {
 	ID string `json:"id"`
 	HandCount int `json:"handCount"`
+	IsAdmin     bool     `json:"isAdmin"`
+	Permissions []string `json:"permissions,omitempty" ts:"Permission[]"`
+	Connection  *ConnectionDTO `json:"connection,omitempty"`
 }
 
 type CardDTO struct {
@@ -63,45 +227,73 @@ type CardDTO struct {
 type ActionDTO struct {
 	ID        		string   `json:"id"`
 	PlayerID 		string   `json:"playerId"`
-	Type      		string   `json:"type"`
+	Type      		string   `json:"type" ts:"ActionType"`
 	Card      		*CardDTO `json:"card,omitempty"`
 	ChallengedBy 	[]string `json:"challengedBy"`
 	AcceptedBy   	[]string `json:"acceptedBy"`
+	Resolution      string   `json:"resolution,omitempty" ts:"ActionResolution"`
 }
 
 type EventDTO struct {
-	Type       string   `json:"type"`
+	Seq        int64    `json:"seq"`
+	Type       string   `json:"type" ts:"EventType"`
 	PlayerID   string   `json:"playerId,omitempty"`
 	ActionID   string   `json:"actionId,omitempty"`
 	ActionType string   `json:"actionType,omitempty"`
 	Card       *CardDTO `json:"card,omitempty"`
 	Penalty    int      `json:"penalty,omitempty"`
+	Discarded  int      `json:"discarded,omitempty"`
+	Overturn   bool     `json:"overturn,omitempty"`
+	Resolution  string   `json:"resolution,omitempty" ts:"ActionResolution"`
+	Challengers []string `json:"challengers,omitempty"`
+	Players     []string `json:"players,omitempty"`
+	Dealt       int      `json:"dealt,omitempty"`
 	Timestamp  int64    `json:"timestamp,omitempty"`
 }
 
+type HistoryPageDTO struct {
+	Events     []EventDTO `json:"events"`
+	NextCursor int64      `json:"nextCursor,omitempty"`
+}
+
+type HistoryFilterDTO struct {
+	PlayerID string   `json:"playerId,omitempty"`
+	Types    []string `json:"types,omitempty" ts:"EventType[]"`
+	Since    int64    `json:"since,omitempty"`
+	Until    int64    `json:"until,omitempty"`
+}
+
+type GetHistoryMessage struct {
+	Type   string           `json:"type"`
+	GameID string           `json:"gameId"`
+	Cursor int64            `json:"cursor,omitempty"`
+	Limit  int              `json:"limit,omitempty"`
+	Filter HistoryFilterDTO `json:"filter,omitempty"`
+}
+
 type ProposePlayCardMessage struct {
 	Type     string  `json:"type"` 
 	GameID   string  `json:"gameId"`
-	PlayerID string  `json:"playerId"`
+	PlayerID string  `json:"playerId,omitempty"`
 	Card     CardDTO `json:"card"`
 }
 
 type ProposeDrawMessage struct {
 	Type     string `json:"type"` 
 	GameID   string `json:"gameId"`
-	PlayerID string `json:"playerId"`
+	PlayerID string `json:"playerId,omitempty"`
 }
 
 type AcceptActionMessage struct {
 	Type     string `json:"type"`
 	GameID   string `json:"gameId"`
-	PlayerID string `json:"playerId"`
+	PlayerID string `json:"playerId,omitempty"`
 }
 
 type ChallengeActionMessage struct {
 	Type     string `json:"type"`
 	GameID   string `json:"gameId"`
-	PlayerID string `json:"playerId"`
+	PlayerID string `json:"playerId,omitempty"`
 }
 
 type ResolveActionMessage struct {
@@ -118,28 +310,254 @@ type AdminPenaltyMessage struct {
 	PenaltyCount 	int    `json:"penaltyCount,omitempty"`
 }
 
-const (
-    writeWait      = 10 * time.Second
-    pongWait       = 60 * time.Second
-    pingInterval   = (pongWait * 9) / 10
-)
+type UpdateSettingsMessage struct {
+	Type     string `json:"type"`
+	GameID   string `json:"gameId"`
+	SettingsDTO
+}
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
+type RuleMessage struct {
+	Type   string `json:"type"`
+	GameID string `json:"gameId"`
+	RuleID string `json:"ruleId,omitempty"`
+	Text   string `json:"text,omitempty"`
+}
+
+type PermissionMessage struct {
+	Type           string          `json:"type"`
+	GameID         string          `json:"gameId"`
+	TargetPlayerID string          `json:"targetPlayerId"`
+	Permission     game.Permission `json:"permission"`
+}
+
+type KickPlayerMessage struct {
+	Type           string `json:"type"`
+	GameID         string `json:"gameId"`
+	TargetPlayerID string `json:"targetPlayerId"`
+}
+
+type AppealVoteMessage struct {
+	Type     string `json:"type"`
+	GameID   string `json:"gameId"`
+	Overturn bool   `json:"overturn"`
+}
+
+// Options configure how WebSocket connections are accepted.
+type Options struct {
+	// EnableCompression negotiates permessage-deflate with clients that
+	// offer it.
+	EnableCompression bool
+	// MaxMessageBytes is the largest inbound message; larger ones close the
+	// connection.
+	MaxMessageBytes int64
+	// AllowedOrigins lists the origins (scheme://host[:port]) pages may
+	// connect from. Empty allows only pages served by this server; "*"
+	// allows any.
+	AllowedOrigins []string
+
+	// ConnLimits and IPLimits throttle each message class per connection
+	// and per client IP; classes without a limit are not throttled.
+	ConnLimits map[string]ratelimit.Limit
+	IPLimits   map[string]ratelimit.Limit
+	// MaxGamesPerIP caps the games an IP may have created that have not
+	// ended. Zero means no cap.
+	MaxGamesPerIP int
+	// ClientIPHeader names a header set by a trusted proxy carrying the
+	// client's IP, e.g. Fly-Client-IP. Empty uses the remote address.
+	ClientIPHeader string
+	// Clock drives the rate limits; nil uses the system clock.
+	Clock ratelimit.Clock
+
+	// WriteWait bounds every write to a socket. PongWait is how long a
+	// socket may stay silent before it is dropped; it is pinged a little
+	// more often than that.
+	WriteWait time.Duration
+	PongWait  time.Duration
+}
+
+func DefaultOptions() Options {
+	return Options{
+		EnableCompression: true,
+		MaxMessageBytes:   64 << 10,
+		ConnLimits:        defaultConnLimits(),
+		IPLimits:          defaultIPLimits(),
+		MaxGamesPerIP:     5,
+		WriteWait:         10 * time.Second,
+		PongWait:          60 * time.Second,
+	}
+}
+
+func (o Options) Validate() error {
+	if o.MaxMessageBytes <= 0 {
+		return errors.New("max message size must be positive")
+	}
+	if o.WriteWait <= 0 || o.PongWait <= 0 {
+		return errors.New("write and pong timeouts must be positive")
+	}
+	for class, limit := range o.ConnLimits {
+		if !limitedClasses[class] {
+			return fmt.Errorf("unknown message class %q in connection limits", class)
+		}
+		if limit.Rate < 0 || (!limit.Unlimited() && limit.Burst < 1) {
+			return fmt.Errorf("invalid connection limit for %s", class)
+		}
+	}
+	for class, limit := range o.IPLimits {
+		if !limitedClasses[class] {
+			return fmt.Errorf("unknown message class %q in IP limits", class)
+		}
+		if limit.Rate < 0 || (!limit.Unlimited() && limit.Burst < 1) {
+			return fmt.Errorf("invalid IP limit for %s", class)
+		}
+	}
+	if o.MaxGamesPerIP < 0 {
+		return errors.New("max games per IP cannot be negative")
+	}
+	for _, origin := range o.AllowedOrigins {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			return fmt.Errorf("invalid allowed origin %q", origin)
+		}
+	}
+	return nil
 }
 
 type Handler struct {
-	// Game will be injected later
+	options  Options
+	games    *game.Registry
+	upgrader websocket.Upgrader
+	limits   *limits
+
+	// dispatching is held for reading by every Dispatch; Shutdown sets
+	// closed and takes it for writing, so no message is applied after it
+	// returns.
+	dispatching sync.RWMutex
+	closed      bool
+
+	// clients, replies and state versions belong to the handler, so two
+	// handlers never see each other's sockets.
+	clientsMu  sync.RWMutex
+	clients    map[*Client]bool
+	replies    *replyCache
+	versionsMu sync.Mutex
+	versions   map[string]int64
+}
+
+func NewHandler(options Options, games *game.Registry) *Handler {
+	h := &Handler{
+		options:  options,
+		games:    games,
+		limits:   newLimits(options, games),
+		clients:  make(map[*Client]bool),
+		replies:  newReplyCache(),
+		versions: make(map[string]int64),
+	}
+	h.upgrader = websocket.Upgrader{
+		Subprotocols:      []string{SubprotocolMsgPack, SubprotocolJSON},
+		EnableCompression: options.EnableCompression,
+	}
+	if len(options.AllowedOrigins) > 0 {
+		h.upgrader.CheckOrigin = h.checkOrigin
+	}
+	return h
+}
+
+// checkOrigin accepts the configured origins. Without a list the upgrader
+// falls back to requiring the page and the socket to share a host.
+func (h *Handler) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, allowed := range h.options.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxMessageBytes is the inbound size limit other transports should apply
+// so they accept the same messages as a socket.
+func (h *Handler) MaxMessageBytes() int64 {
+	return h.options.MaxMessageBytes
+}
+
+// GameChanged pushes fresh state to every socket in a game after it was
+// changed through another transport.
+func (h *Handler) GameChanged(gameID string) {
+	g, err := h.games.GetGame(gameID)
+	if err != nil {
+		return
+	}
+	g.Lock()
+	defer g.Unlock()
+
+	h.broadcastGameState(gameID, g)
+}
+
+// SessionEnded pushes the final state and the session summary to every
+// client in a game, whichever transport ended it.
+func (h *Handler) SessionEnded(gameID string) {
+	g, err := h.games.GetGame(gameID)
+	if err != nil {
+		return
+	}
+	g.Lock()
+	defer g.Unlock()
+
+	h.sessionEnded(g)
+}
+
+// sessionEnded broadcasts the end of g's session; the caller holds its
+// lock.
+func (h *Handler) sessionEnded(g *game.Game) {
+	gameID := g.ID
+	h.broadcastGameState(gameID, g)
+	h.broadcastMessage(gameID, ServerMessage{
+		Type: "SESSION_SUMMARY",
+		Payload: SessionSummary{
+			GameID:    g.ID,
+			Rounds:    toRoundResultDTOs(g),
+			Standings: toStandingDTOs(g),
+		},
+	})
+}
+
+func (h *Handler) PlayerRemoved(gameID, playerID string) {
+	h.disconnectPlayer(gameID, playerID)
 }
 
-func NewHandler() *Handler {
-	return &Handler{}
+// Attach registers a connection so it receives broadcasts once it joins a
+// game. ip is the client address rate limits apply to. Every Attach must be
+// paired with a Detach.
+func (h *Handler) Attach(conn Conn, ip string) *Client {
+	c := &Client{Conn: conn, ip: ip}
+	c.latency.staleAfter = h.options.PongWait
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+
+	h.clientsMu.Lock()
+	h.clients[c] = true
+	h.clientsMu.Unlock()
+	return c
+}
+
+func (h *Handler) Detach(c *Client) {
+	c.replay.stop()
+	c.cancel()
+
+	h.clientsMu.Lock()
+	delete(h.clients, c)
+	h.clientsMu.Unlock()
+
+	c.Conn.Close()
 }
 
 func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+	conn, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("websocket upgrade failed: %v", err)
 		return
@@ -147,300 +565,656 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("websocket connected: %s", r.RemoteAddr)
 
+	c := h.Attach(wsConn{conn, h.options.WriteWait}, h.ClientIP(r))
+	defer h.Detach(c)
+
+	conn.SetReadLimit(h.options.MaxMessageBytes)
+
+	pongWait := h.options.PongWait
 	conn.SetReadDeadline(time.Now().Add(pongWait))
-    conn.SetPongHandler(func(string) error {
+    conn.SetPongHandler(func(appData string) error {
         conn.SetReadDeadline(time.Now().Add(pongWait))
+        if sent, err := strconv.ParseInt(appData, 10, 64); err == nil {
+            c.latency.observeRTT(time.Since(time.Unix(0, sent)))
+        }
         return nil
     })
 
-    ticker := time.NewTicker(pingInterval)
+    ticker := time.NewTicker(pongWait * 9 / 10)
     defer ticker.Stop()
 
     go func() {
         for range ticker.C {
-            conn.SetWriteDeadline(time.Now().Add(writeWait))
-            if err := conn.WriteMessage(websocket.PingMessage, []byte{}); err != nil {
+            // the pong echoes the send time, which gives the round trip
+            stamp := []byte(strconv.FormatInt(time.Now().UnixNano(), 10))
+            if err := conn.WriteControl(websocket.PingMessage, stamp, time.Now().Add(h.options.WriteWait)); err != nil {
                 return
             }
         }
     }()
 
-	defer func() {
-		delete(clients, conn)
-		conn.Close()
-	}()
-
 	for {
-		_, messageBytes, err := conn.ReadMessage()
+		frameType, messageBytes, err := conn.ReadMessage()
 		if err != nil {
 			return
 		}
 
+		if frameType == websocket.BinaryMessage {
+			if messageBytes, err = msgpack.ToJSON(messageBytes); err != nil {
+				log.Printf("invalid message: %v", err)
+				continue
+			}
+		}
+
 		conn.SetReadDeadline(time.Now().Add(pongWait))
 
-		var msg ClientMessage
-		if err := json.Unmarshal(messageBytes, &msg); err != nil {
-			log.Printf("invalid message: %v", err)
-			continue
+		if err := h.Dispatch(c, messageBytes); err != nil {
+			return
 		}
+	}
+}
 
-		switch msg.Type {
-		case "CREATE_GAME":
-			if msg.Name == "" {
-				log.Printf("CREATE_GAME missing name")
-				continue
-			}
+// Dispatch applies one client message, whichever transport it arrived on.
+// Messages carrying a requestId are applied at most once per player. Clients
+// that negotiated acks are answered with ACK or ERROR; a rejected message
+// without a requestId still gets an ERROR. A returned error means the
+// client can no longer be written to, or that the server is shutting down.
+func (h *Handler) Dispatch(c *Client, messageBytes []byte) error {
+	var msg ClientMessage
+	if err := json.Unmarshal(messageBytes, &msg); err != nil {
+		log.Printf("invalid message: %v", err)
+		if !c.supports(CapAcks) {
+			return nil
+		}
+		return c.Send(ServerMessage{Type: "ERROR", Payload: ErrorDTO{Message: "invalid message"}})
+	}
 
-			player := &game.Player{
-				ID: msg.Name,
-			}
+	h.dispatching.RLock()
+	defer h.dispatching.RUnlock()
+	if h.closed {
+		return game.ErrShuttingDown
+	}
 
-			newGame, err := game.CreateGame(player)
-			if err != nil {
-			log.Printf("create game failed: %v", err)
-			continue
-			}
+	if g := h.lockGame(c, msg); g != nil {
+		defer g.Unlock()
+	}
 
-			client := &Client{
-				Conn:     conn,
-				GameID:   newGame.ID,
-				PlayerID: player.ID,
-			}
+	var key requestKey
+	if msg.RequestID != "" && c.PlayerID != "" {
+		key = requestKey{gameID: c.GameID, playerID: c.PlayerID, requestID: msg.RequestID}
+	}
+
+	var reply ServerMessage
+	var err error
+	status := replyNew
+	if key.requestID != "" {
+		reply, status = h.replies.reserve(key)
+	}
+	switch status {
+	case replyCached:
+		return c.Send(reply)
+	case replyPending:
+		err = inProgress()
+	default:
+		err = h.limits.allow(c, messageClass(msg.Type))
+		if err == nil {
+			err = h.handle(c, msg, messageBytes)
+		}
+	}
+	var rejected *requestError
+	switch {
+	case errors.As(err, &rejected):
+		if rejected.code != "RATE_LIMITED" {
+			log.Printf("%s", rejected.msg)
+		}
+		reply = ServerMessage{Type: "ERROR", Payload: ErrorDTO{
+			RequestID:        msg.RequestID,
+			Type:             msg.Type,
+			Code:             rejected.code,
+			Message:          rejected.msg,
+			RetryAfterMillis: rejected.retryAfter.Milliseconds(),
+		}}
+	case err != nil:
+		if status == replyNew && key.requestID != "" {
+			h.replies.release(key)
+		}
+		return err
+	case msg.RequestID != "":
+		reply = ServerMessage{Type: "ACK", Payload: AckDTO{RequestID: msg.RequestID, Type: msg.Type}}
+	default:
+		return nil
+	}
 
-			clients[conn] = client
+	// a throttled request was not applied, so a resend must be tried again;
+	// a resend of one still in progress leaves the original's reservation
+	switch {
+	case key.requestID == "" || status != replyNew:
+	case ErrorCode(err) == "RATE_LIMITED":
+		h.replies.release(key)
+	default:
+		h.replies.store(key, reply)
+	}
+	if !c.supports(CapAcks) {
+		return nil
+	}
+	return c.Send(reply)
+}
 
+// lockGame locks the game a message acts on, if there is one, so messages
+// from every transport, HTTP requests and background tasks change a game
+// one at a time. Seated messages only ever act on the sender's own game.
+func (h *Handler) lockGame(c *Client, msg ClientMessage) *game.Game {
+	gameID, _ := c.seat()
+	switch {
+	case msg.Type == "JOIN_GAME" || msg.Type == "GET_HISTORY":
+		gameID = msg.GameID
+	case msg.Type != "RESYNC" && !clientMessageSeated(msg.Type):
+		return nil
+	}
 
-			state := ServerMessage{
-				Type: "GAME_STATE",
-				Payload: toPlayerGameState(newGame, player.ID),
-			}
+	g, err := h.games.GetGame(gameID)
+	if err != nil {
+		return nil
+	}
+	g.Lock()
+	return g
+}
 
-			conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if err := conn.WriteJSON(state); err != nil {
-				log.Printf("write failed: %v", err)
-				return
-			}
+// handle applies a parsed message. Failures caused by the message itself
+// come back as a *requestError; any other error is a failed write.
+func (h *Handler) handle(c *Client, msg ClientMessage, messageBytes []byte) error {
+	if !clientMessageKnown(msg.Type) {
+		return reject("unknown message type: %s", msg.Type)
+	}
+	if clientMessageSeated(msg.Type) {
+		gameID, playerID := c.seat()
+		if playerID == "" || msg.GameID != gameID {
+			return reject("%s for a game the client is not seated in", msg.Type)
+		}
+	}
 
-		case "JOIN_GAME":
-		if msg.GameID == "" {
-			log.Printf("JOIN_GAME missing gameId")
-			continue
+	switch msg.Type {
+	case "HELLO":
+		var payload HelloMessage
+		if err := json.Unmarshal(messageBytes, &payload); err != nil {
+			return reject("invalid HELLO payload: %v", err)
+		}
+
+		welcome, err := c.negotiate(payload)
+		if err != nil {
+			log.Printf("refusing client: %v", err)
+			c.Send(ServerMessage{Type: "ERROR", Payload: ErrorDTO{
+				RequestID: msg.RequestID,
+				Type:      msg.Type,
+				Code:      "UNSUPPORTED_PROTOCOL",
+				Message:   err.Error(),
+			}})
+			return err
+		}
+
+		if err := c.Send(ServerMessage{Type: "WELCOME", Payload: welcome}); err != nil {
+			log.Printf("write failed: %v", err)
+			return err
 		}
 
+	case "CREATE_GAME":
 		if msg.Name == "" {
-			log.Printf("JOIN_GAME missing name")
-			continue
+			return reject("CREATE_GAME missing name")
 		}
 
 		player := &game.Player{
 			ID: msg.Name,
 		}
 
-		joinedGame, err := game.JoinGame(msg.GameID, player)
+		if err := h.limits.admitGame(c.ip); err != nil {
+			return err
+		}
+
+		newGame, err := h.games.CreateGame(player)
 		if err != nil {
-			log.Printf("join game failed: %v", err)
-			continue
+		return reject("create game failed: %v", err)
 		}
 
-		client := &Client{
-			Conn:     conn,
-			GameID:   msg.GameID,
-			PlayerID: player.ID,
+		h.limits.gameCreated(c.ip, newGame.ID)
+		c.bind(newGame.ID, player.ID)
+
+		newGame.Lock()
+		err = h.sendState(c, newGame, false)
+		newGame.Unlock()
+		if err != nil {
+			log.Printf("write failed: %v", err)
+			return err
 		}
 
-		clients[conn] = client
+	case "JOIN_GAME":
+	if msg.GameID == "" {
+		return reject("JOIN_GAME missing gameId")
+	}
 
-		broadcastGameState(msg.GameID, joinedGame)
+	if msg.Name == "" {
+		return reject("JOIN_GAME missing name")
+	}
 
-		case "START_GAME":
-		if msg.GameID == "" {
-			log.Printf("START_GAME missing gameId or playerId")
-			continue
-		}
+	player := &game.Player{
+		ID: msg.Name,
+	}
 
-		gameInstance, err := game.GetGame(msg.GameID)
-		if err != nil {
-			log.Printf("start game failed: %v", err)
-			continue
+	joinedGame, err := h.games.JoinGame(msg.GameID, player)
+	if err != nil {
+		if msg.Token == "" {
+			return reject("join game failed: %v", err)
 		}
+		rejoined, rejoinErr := h.games.RejoinGame(msg.GameID, player.ID, msg.Token)
+		if rejoinErr != nil {
+			return reject("rejoin failed: %v", rejoinErr)
+		}
+		// the token proves the seat is this client's; a socket still holding
+		// it is a stale one the player has left
+		h.replaceSeat(msg.GameID, player.ID, c)
+		joinedGame = rejoined
+	}
 
-		client := clients[conn]
+	c.bind(msg.GameID, player.ID)
 
-		if err := gameInstance.StartGame(client.PlayerID); err != nil {
-			log.Printf("start game failed: %v", err)
-			continue
-		}
+	h.broadcastGameState(msg.GameID, joinedGame)
 
-		broadcastGameState(msg.GameID, gameInstance)
+	case "START_GAME":
+	if msg.GameID == "" {
+		return reject("START_GAME missing gameId or playerId")
+	}
 
-		case "PROPOSE_PLAY":
-		var payload ProposePlayCardMessage
-		if err := json.Unmarshal(messageBytes, &payload); err != nil {
-			log.Printf("invalid PROPOSE_PLAY payload: %v", err)
-			continue
-		}
+	gameInstance, err := h.games.GetGame(msg.GameID)
+	if err != nil {
+		return reject("start game failed: %v", err)
+	}
 
-		g, err := game.GetGame(payload.GameID)
-		if err != nil {
-			log.Printf("game not found: %v", err)
-			continue
-		}
+	if err := gameInstance.StartGame(c.PlayerID); err != nil {
+		return reject("start game failed: %v", err)
+	}
 
-		client := clients[conn]
+	h.broadcastGameState(msg.GameID, gameInstance)
 
-		action := &game.Action{
-			ID:       time.Now().Format(time.RFC3339Nano),
-			PlayerID: client.PlayerID,
-			Type:     game.ActionPlayCard,
-			Card: &game.Card{
-				Rank: payload.Card.Rank,
-				Suit: payload.Card.Suit,
-			},
-			AcceptedBy:   make(map[string]bool),
-			ChallengedBy: make(map[string]bool),
-		}
+	case "PROPOSE_PLAY":
+	var payload ProposePlayCardMessage
+	if err := json.Unmarshal(messageBytes, &payload); err != nil {
+		return reject("invalid PROPOSE_PLAY payload: %v", err)
+	}
 
-		if err := g.ProposeAction(action); err != nil {
-			log.Printf("cannot propose action: %v", err)
-			continue
-		}
+	g, err := h.games.GetGame(payload.GameID)
+	if err != nil {
+		return reject("game not found: %v", err)
+	}
 
-		broadcastGameState(payload.GameID, g)
+	action := &game.Action{
+		ID:       time.Now().Format(time.RFC3339Nano),
+		PlayerID: c.PlayerID,
+		Type:     game.ActionPlayCard,
+		Card: &game.Card{
+			Rank: payload.Card.Rank,
+			Suit: payload.Card.Suit,
+		},
+		AcceptedBy:   make(map[string]bool),
+		ChallengedBy: make(map[string]bool),
+	}
 
-		case "PROPOSE_DRAW":
-		var payload ProposeDrawMessage
-		if err := json.Unmarshal(messageBytes, &payload); err != nil {
-			log.Printf("invalid PROPOSE_DRAW payload: %v", err)
-			continue
-		}
+	if err := g.ProposeAction(action); err != nil {
+		return reject("cannot propose action: %v", err)
+	}
 
-		g, err := game.GetGame(payload.GameID)
-		if err != nil {
-			log.Printf("game not found: %v", err)
-			continue
-		}
+	h.broadcastGameState(payload.GameID, g)
 
-		client := clients[conn]
+	case "PROPOSE_DRAW":
+	var payload ProposeDrawMessage
+	if err := json.Unmarshal(messageBytes, &payload); err != nil {
+		return reject("invalid PROPOSE_DRAW payload: %v", err)
+	}
 
-		action := &game.Action{
-			ID:       time.Now().Format(time.RFC3339Nano),
-			PlayerID: client.PlayerID,
-			Type:     game.ActionDraw,
-			AcceptedBy:   make(map[string]bool),
-			ChallengedBy: make(map[string]bool),
-		}
+	g, err := h.games.GetGame(payload.GameID)
+	if err != nil {
+		return reject("game not found: %v", err)
+	}
 
-		if err := g.ProposeAction(action); err != nil {
-			log.Printf("cannot propose action: %v", err)
-			continue
-		}
+	action := &game.Action{
+		ID:       time.Now().Format(time.RFC3339Nano),
+		PlayerID: c.PlayerID,
+		Type:     game.ActionDraw,
+		AcceptedBy:   make(map[string]bool),
+		ChallengedBy: make(map[string]bool),
+	}
 
-		broadcastGameState(payload.GameID, g)
+	if err := g.ProposeAction(action); err != nil {
+		return reject("cannot propose action: %v", err)
+	}
 
-		case "ACCEPT_ACTION":
-		var payload AcceptActionMessage
-		if err := json.Unmarshal(messageBytes, &payload); err != nil {
-			log.Printf("invalid ACCEPT_ACTION payload: %v", err)
-			continue
-		}
+	h.broadcastGameState(payload.GameID, g)
 
-		g, err := game.GetGame(payload.GameID)
-		if err != nil {
-			log.Printf("game not found: %v", err)
-			continue
-		}
+	case "ACCEPT_ACTION":
+	var payload AcceptActionMessage
+	if err := json.Unmarshal(messageBytes, &payload); err != nil {
+		return reject("invalid ACCEPT_ACTION payload: %v", err)
+	}
 
-		client := clients[conn]
+	g, err := h.games.GetGame(payload.GameID)
+	if err != nil {
+		return reject("game not found: %v", err)
+	}
 
-		if err := g.AcceptAction(client.PlayerID); err != nil {
-			log.Printf("cannot accept action: %v", err)
-			continue
-		}
+	if err := g.AcceptAction(c.PlayerID); err != nil {
+		return reject("cannot accept action: %v", err)
+	}
 
-		broadcastGameState(payload.GameID, g)
+	h.broadcastGameState(payload.GameID, g)
 
-		case "CHALLENGE_ACTION":
-		var payload ChallengeActionMessage
-		if err := json.Unmarshal(messageBytes, &payload); err != nil {
-			log.Printf("invalid CHALLENGE_ACTION payload: %v", err)
-			continue
-		}
+	case "CHALLENGE_ACTION":
+	var payload ChallengeActionMessage
+	if err := json.Unmarshal(messageBytes, &payload); err != nil {
+		return reject("invalid CHALLENGE_ACTION payload: %v", err)
+	}
 
-		g, err := game.GetGame(payload.GameID)
-		if err != nil {
-			log.Printf("game not found: %v", err)
-			continue
-		}
+	g, err := h.games.GetGame(payload.GameID)
+	if err != nil {
+		return reject("game not found: %v", err)
+	}
 
-		client := clients[conn]
+	if err := g.ChallengeAction(c.PlayerID); err != nil {
+		return reject("cannot challenge action: %v", err)
+	}
 
-		if err := g.ChallengeAction(client.PlayerID); err != nil {
-			log.Printf("cannot challenge action: %v", err)
-			continue
-		}
+	h.broadcastGameState(payload.GameID, g)
 
-		broadcastGameState(payload.GameID, g)
+	case "RESOLVE_ACTION":
+	var payload ResolveActionMessage
+	if err := json.Unmarshal(messageBytes, &payload); err != nil {
+		return reject("invalid RESOLVE_ACTION payload: %v", err)
+	}
 
-		case "RESOLVE_ACTION":
-		var payload ResolveActionMessage
-		if err := json.Unmarshal(messageBytes, &payload); err != nil {
-			log.Printf("invalid RESOLVE_ACTION payload: %v", err)
-			continue
-		}
+	g, err := h.games.GetGame(payload.GameID)
+	if err != nil {
+		return reject("game not found: %v", err)
+	}
 
-		g, err := game.GetGame(payload.GameID)
-		if err != nil {
-			log.Printf("game not found: %v", err)
-			continue
-		}
+	err = g.ResolveAction(
+		c.PlayerID,
+		payload.Resolution,
+		payload.PenaltyCount,
+	)
 
-		client := clients[conn]
+	if err != nil {
+		return reject("cannot resolve action: %v", err)
+	}
 
-		err = g.ResolveAction(
-			client.PlayerID,
-			payload.Resolution,
-			payload.PenaltyCount,
-		)
+	h.broadcastGameState(payload.GameID, g)
 
-		if err != nil {
-			log.Printf("cannot resolve action: %v", err)
-			continue
-		}
+	case "ADMIN_PENALIZE":
+	var payload AdminPenaltyMessage
+	if err := json.Unmarshal(messageBytes, &payload); err != nil {
+		return reject("invalid ADMIN_PENALIZE payload: %v", err)
+	}
+
+	g, err := h.games.GetGame(payload.GameID)
+	if err != nil {
+		return reject("game not found: %v", err)
+	}
+
+	if err := g.PenalizePlayer(c.PlayerID, payload.TargetPlayerID, payload.PenaltyCount); err != nil {
+		return reject("cannot apply penalty: %v", err)
+	}
+
+	h.broadcastGameState(payload.GameID, g)
+
+	case "GRANT_PERMISSION", "REVOKE_PERMISSION":
+	var payload PermissionMessage
+	if err := json.Unmarshal(messageBytes, &payload); err != nil {
+		return reject("invalid %s payload: %v", msg.Type, err)
+	}
+
+	g, err := h.games.GetGame(payload.GameID)
+	if err != nil {
+		return reject("game not found: %v", err)
+	}
+
+	if msg.Type == "GRANT_PERMISSION" {
+		err = g.GrantPermission(c.PlayerID, payload.TargetPlayerID, payload.Permission)
+	} else {
+		err = g.RevokePermission(c.PlayerID, payload.TargetPlayerID, payload.Permission)
+	}
+
+	if err != nil {
+		return reject("cannot change permission: %v", err)
+	}
+
+	h.broadcastGameState(payload.GameID, g)
+
+	case "KICK_PLAYER":
+	var payload KickPlayerMessage
+	if err := json.Unmarshal(messageBytes, &payload); err != nil {
+		return reject("invalid KICK_PLAYER payload: %v", err)
+	}
+
+	g, err := h.games.GetGame(payload.GameID)
+	if err != nil {
+		return reject("game not found: %v", err)
+	}
+
+	if err := g.KickPlayer(c.PlayerID, payload.TargetPlayerID); err != nil {
+		return reject("cannot kick player: %v", err)
+	}
+
+	h.disconnectPlayer(payload.GameID, payload.TargetPlayerID)
+	h.broadcastGameState(payload.GameID, g)
+
+	case "UPDATE_SETTINGS":
+	var payload UpdateSettingsMessage
+	if err := json.Unmarshal(messageBytes, &payload); err != nil {
+		return reject("invalid UPDATE_SETTINGS payload: %v", err)
+	}
+
+	g, err := h.games.GetGame(payload.GameID)
+	if err != nil {
+		return reject("game not found: %v", err)
+	}
+
+	if err := g.UpdateSettings(c.PlayerID, payload.SettingsDTO.Settings()); err != nil {
+		return reject("cannot update settings: %v", err)
+	}
+
+	h.broadcastGameState(payload.GameID, g)
+
+	case "ADD_RULE", "EDIT_RULE", "REMOVE_RULE":
+	var payload RuleMessage
+	if err := json.Unmarshal(messageBytes, &payload); err != nil {
+		return reject("invalid %s payload: %v", msg.Type, err)
+	}
+
+	g, err := h.games.GetGame(payload.GameID)
+	if err != nil {
+		return reject("game not found: %v", err)
+	}
+
+	switch msg.Type {
+	case "ADD_RULE":
+		_, err = g.AddRule(c.PlayerID, payload.Text)
+	case "EDIT_RULE":
+		err = g.EditRule(c.PlayerID, payload.RuleID, payload.Text)
+	case "REMOVE_RULE":
+		err = g.RemoveRule(c.PlayerID, payload.RuleID)
+	}
+
+	if err != nil {
+		return reject("cannot update rulebook: %v", err)
+	}
+
+	h.broadcastGameState(payload.GameID, g)
+
+	case "SUBMIT_RULE":
+	var payload RuleMessage
+	if err := json.Unmarshal(messageBytes, &payload); err != nil {
+		return reject("invalid SUBMIT_RULE payload: %v", err)
+	}
+
+	g, err := h.games.GetGame(payload.GameID)
+	if err != nil {
+		return reject("game not found: %v", err)
+	}
+
+	if err := g.SubmitWinnerRule(c.PlayerID, payload.Text); err != nil {
+		return reject("cannot submit rule: %v", err)
+	}
+
+	h.broadcastGameState(payload.GameID, g)
+
+	case "START_NEXT_ROUND":
+	g, err := h.games.GetGame(msg.GameID)
+	if err != nil {
+		return reject("game not found: %v", err)
+	}
+
+	if err := g.StartNextRound(c.PlayerID); err != nil {
+		return reject("cannot start next round: %v", err)
+	}
+
+	h.broadcastGameState(msg.GameID, g)
+
+	case "END_SESSION":
+	g, err := h.games.GetGame(msg.GameID)
+	if err != nil {
+		return reject("game not found: %v", err)
+	}
+
+	if err := g.EndSession(c.PlayerID); err != nil {
+		return reject("cannot end session: %v", err)
+	}
+
+	h.sessionEnded(g)
+
+	case "GET_HISTORY":
+	var payload GetHistoryMessage
+	if err := json.Unmarshal(messageBytes, &payload); err != nil {
+		return reject("invalid GET_HISTORY payload: %v", err)
+	}
+
+	g, err := h.games.GetGame(payload.GameID)
+	if err != nil {
+		return reject("game not found: %v", err)
+	}
+
+	page, err := g.History(payload.Filter.Query(payload.Cursor, payload.Limit))
+	if err != nil {
+		return reject("cannot load history: %v", err)
+	}
+
+	if err := c.Send(ServerMessage{Type: "HISTORY", Payload: ToHistoryPageDTO(page)}); err != nil {
+		log.Printf("write failed: %v", err)
+		return err
+	}
+
+	case "REPLAY":
+	var payload ReplayMessage
+	if err := json.Unmarshal(messageBytes, &payload); err != nil {
+		return reject("invalid REPLAY payload: %v", err)
+	}
+
+	frames, err := h.games.Replay(payload.GameID)
+	if err != nil {
+		return reject("cannot replay game: %v", err)
+	}
+
+	c.replay.start(c.ctx, func(replayCtx context.Context) {
+		c.streamReplay(replayCtx, frames, payload.FromStep, payload.Speed)
+	})
 
-		broadcastGameState(payload.GameID, g)
+	case "REPLAY_STEP":
+	var payload ReplayMessage
+	if err := json.Unmarshal(messageBytes, &payload); err != nil {
+		return reject("invalid REPLAY_STEP payload: %v", err)
+	}
+
+	frame, err := h.games.ReplayStep(payload.GameID, payload.Step)
+	if err != nil {
+		return reject("cannot replay game: %v", err)
+	}
+
+	if err := c.Send(ServerMessage{Type: "REPLAY_FRAME", Payload: ToReplayFrameDTO(frame)}); err != nil {
+		log.Printf("write failed: %v", err)
+		return err
+	}
+
+	case "REPLAY_STOP":
+	c.replay.stop()
 
-		case "ADMIN_PENALIZE":
-		var payload AdminPenaltyMessage
+	case "PING":
+		var payload PingMessage
 		if err := json.Unmarshal(messageBytes, &payload); err != nil {
-			log.Printf("invalid ADMIN_PENALIZE payload: %v", err)
-			continue
+			return reject("invalid PING payload: %v", err)
 		}
 
-		g, err := game.GetGame(payload.GameID)
-		if err != nil {
-			log.Printf("game not found: %v", err)
-			continue
+		now := time.Now()
+		if payload.RTT > 0 {
+			c.latency.observeRTT(time.Duration(payload.RTT) * time.Millisecond)
+		}
+		if payload.ClientTime > 0 {
+			c.latency.observeClock(time.UnixMilli(payload.ClientTime), now)
 		}
 
-		client := clients[conn]
-		if client.PlayerID != g.AdminID {
-			continue
+		pong := PongDTO{ClientTime: payload.ClientTime, ServerTime: now.UnixMilli()}
+		if err := c.Send(ServerMessage{Type: "PONG", Payload: pong}); err != nil {
+			log.Printf("write failed: %v", err)
+			return err
+		}
+
+	case "RESYNC":
+		g, err := h.games.GetGame(c.GameID)
+		if err != nil {
+			return reject("RESYNC outside a game: %v", err)
 		}
-		g.ApplyPenalty(payload.TargetPlayerID, payload.PenaltyCount)
-		broadcastGameState(payload.GameID, g)
 
-		default:
-			log.Printf("unknown message type: %s", msg.Type)
+		if err := h.sendState(c, g, false); err != nil {
+			log.Printf("write failed: %v", err)
+			return err
 		}
+
+	case "CALL_APPEAL":
+	g, err := h.games.GetGame(msg.GameID)
+	if err != nil {
+		return reject("game not found: %v", err)
+	}
+
+	if err := g.CallAppeal(c.PlayerID); err != nil {
+		return reject("cannot call appeal: %v", err)
+	}
+
+	h.broadcastGameState(msg.GameID, g)
+
+	case "APPEAL_VOTE":
+	var payload AppealVoteMessage
+	if err := json.Unmarshal(messageBytes, &payload); err != nil {
+		return reject("invalid APPEAL_VOTE payload: %v", err)
+	}
+
+	g, err := h.games.GetGame(payload.GameID)
+	if err != nil {
+		return reject("game not found: %v", err)
+	}
+
+	if err := g.VoteAppeal(c.PlayerID, payload.Overturn); err != nil {
+		return reject("cannot vote on appeal: %v", err)
+	}
+
+	h.broadcastGameState(payload.GameID, g)
+
+	default:
+		return reject("unknown message type: %s", msg.Type)
 	}
+	return nil
 }
 
-func toPlayerGameState(g *game.Game, playerID string) PlayerGameState {
+// ToPlayerGameState is g as playerID sees it, at the state version this
+// handler last broadcast.
+func (h *Handler) ToPlayerGameState(g *game.Game, playerID string) PlayerGameState {
 	players := make([]PlayerInfo, 0, len(g.Players))
 	var hand []CardDTO
-	var actionDTO *ActionDTO
-	var lastActionDTO *ActionDTO
 	var topCard *CardDTO
+	var seatToken string
 	
 	if g.TopCard != nil {
 		topCard = &CardDTO{
@@ -449,56 +1223,24 @@ func toPlayerGameState(g *game.Game, playerID string) PlayerGameState {
 		}
 	}
 
-	if g.CurrentAction != nil {
-		actionDTO = &ActionDTO{
-			ID:        g.CurrentAction.ID,
-			PlayerID: g.CurrentAction.PlayerID,
-			Type:     string(g.CurrentAction.Type),
-		}
-
-		for pid := range g.CurrentAction.ChallengedBy {
-			actionDTO.ChallengedBy = append(actionDTO.ChallengedBy, pid)
-		}
-
-		for pid := range g.CurrentAction.AcceptedBy {
-			actionDTO.AcceptedBy = append(actionDTO.AcceptedBy, pid)
-		}
-
-		if g.CurrentAction.Card != nil {
-			actionDTO.Card = &CardDTO{
-				Rank: g.CurrentAction.Card.Rank,
-				Suit: g.CurrentAction.Card.Suit,
-			}
-		}
-	}
-
-	if g.LastSuccessfulAction != nil {
-		lastActionDTO = &ActionDTO{
-			ID:        g.LastSuccessfulAction.ID,
-			PlayerID: g.LastSuccessfulAction.PlayerID,
-			Type:     string(g.LastSuccessfulAction.Type),
-		}
-
-		for pid := range g.LastSuccessfulAction.ChallengedBy {
-			lastActionDTO.ChallengedBy = append(lastActionDTO.ChallengedBy, pid)
-		}
+	actionDTO := toActionDTO(g.CurrentAction)
+	lastActionDTO := toActionDTO(g.LastSuccessfulAction)
+	lastRejectedDTO := toActionDTO(g.LastRejectedAction)
 
-		for pid := range g.LastSuccessfulAction.AcceptedBy {
-			lastActionDTO.AcceptedBy = append(lastActionDTO.AcceptedBy, pid)
+	for _, p := range g.Players {
+		info := PlayerInfo{
+			ID:        p.ID,
+			HandCount: len(p.Hand),
+			IsAdmin:   p.ID == g.AdminID,
 		}
-
-		if g.LastSuccessfulAction.Card != nil {
-			lastActionDTO.Card = &CardDTO{
-				Rank: g.LastSuccessfulAction.Card.Rank,
-				Suit: g.LastSuccessfulAction.Card.Suit,
-			}
+		for _, perm := range g.Permissions(p.ID) {
+			info.Permissions = append(info.Permissions, string(perm))
 		}
-	}
-
-	for _, p := range g.Players {
-		players = append(players, PlayerInfo{ID: p.ID, HandCount: len(p.Hand)})
+		info.Connection = h.connectionOf(g.ID, p.ID)
+		players = append(players, info)
 
 		if p.ID == playerID {
+			seatToken = p.Token
 			for _, c := range p.Hand {
 				hand = append(hand, CardDTO{
 					Rank: c.Rank,
@@ -510,21 +1252,36 @@ func toPlayerGameState(g *game.Game, playerID string) PlayerGameState {
 
 	var recentEvents []EventDTO
 	for _, e := range g.RecentEvents {
-		eventDTO := EventDTO{
-			Type:       string(e.Type),
-			PlayerID:   e.PlayerID,
-			ActionID:   e.ActionID,
-			ActionType: e.ActionType,
-			Penalty:    e.Penalty,
-			Timestamp:  e.Timestamp,
-		}
-		if e.Card != nil {
-			eventDTO.Card = &CardDTO{
-				Rank: e.Card.Rank,
-				Suit: e.Card.Suit,
+		recentEvents = append(recentEvents, ToEventDTO(e))
+	}
+
+	var appeal *AppealDTO
+	if g.Appeal != nil {
+		appeal = &AppealDTO{
+			ActionID: g.Appeal.Action.ID,
+			CalledBy: g.Appeal.CalledBy,
+			Deadline: g.Appeal.Deadline.Unix(),
+			Required: g.Appeal.Required,
+		}
+		for pid, overturn := range g.Appeal.Votes {
+			if overturn {
+				appeal.OverturnBy = append(appeal.OverturnBy, pid)
+			} else {
+				appeal.UpholdBy = append(appeal.UpholdBy, pid)
 			}
 		}
-		recentEvents = append(recentEvents, eventDTO)
+	}
+
+	var rulebook []RuleDTO
+	if rules, err := g.Rules(playerID); err == nil {
+		for _, r := range rules {
+			rulebook = append(rulebook, RuleDTO{
+				ID:        r.ID,
+				Text:      r.Text,
+				CreatedAt: r.CreatedAt.Unix(),
+				AddedBy:   r.AddedBy,
+			})
+		}
 	}
 
 	return PlayerGameState{
@@ -534,29 +1291,211 @@ func toPlayerGameState(g *game.Game, playerID string) PlayerGameState {
 		Players:  players,
 		Hand:     hand,
 		PlayerID: playerID,
+		SeatToken: seatToken,
 		CurrentAction: actionDTO,
 		TopCard: topCard,
 		LastAction: lastActionDTO,
+		LastRejectedAction: lastRejectedDTO,
 		WinnerID: g.WinnerID,
 		RecentEvents: recentEvents,
+		Settings: ToSettingsDTO(g.Settings),
+		Appeal: appeal,
+		Rulebook: rulebook,
+		Round: g.Session.Round,
+		RoundResults: toRoundResultDTOs(g),
+		PendingRuleFrom: g.Session.PendingRuleFrom,
+		Standings: toStandingDTOs(g),
+		SessionEnded: g.Session.Ended,
+		Version: h.stateVersion(g.ID),
 	}
 
 }
 
-func broadcastGameState(gameID string, g *game.Game) {
-	for _, client := range clients {
-		if client.GameID != gameID {
-			continue
+func ToSettingsDTO(s game.Settings) SettingsDTO {
+	return SettingsDTO{
+		AppealsEnabled:      s.AppealsEnabled,
+		AppealWindowSeconds: int(s.AppealWindow / time.Second),
+		AppealMajority:      s.AppealMajority,
+		RevealRulebookOnEnd: s.RevealRulebookOnEnd,
+		ChallengePolicy: ChallengePolicyDTO{
+			WrongChallengerPenalty:   s.ChallengePolicy.WrongChallengerPenalty,
+			WrongAcceptorPenalty:     s.ChallengePolicy.WrongAcceptorPenalty,
+			CorrectChallengerDiscard: s.ChallengePolicy.CorrectChallengerDiscard,
+		},
+	}
+}
+
+func (s SettingsDTO) Settings() game.Settings {
+	return game.Settings{
+		AppealsEnabled:      s.AppealsEnabled,
+		AppealWindow:        time.Duration(s.AppealWindowSeconds) * time.Second,
+		AppealMajority:      s.AppealMajority,
+		RevealRulebookOnEnd: s.RevealRulebookOnEnd,
+		ChallengePolicy: game.ChallengePolicy{
+			WrongChallengerPenalty:   s.ChallengePolicy.WrongChallengerPenalty,
+			WrongAcceptorPenalty:     s.ChallengePolicy.WrongAcceptorPenalty,
+			CorrectChallengerDiscard: s.ChallengePolicy.CorrectChallengerDiscard,
+		},
+	}
+}
+
+func ToEventDTO(e game.Event) EventDTO {
+	eventDTO := EventDTO{
+		Seq:        e.Seq,
+		Type:       string(e.Type),
+		PlayerID:   e.PlayerID,
+		ActionID:   e.ActionID,
+		ActionType: e.ActionType,
+		Penalty:    e.Penalty,
+		Discarded:  e.Discarded,
+		Overturn:   e.Overturn,
+		Resolution: e.Resolution,
+		Challengers: e.Challengers,
+		Players:    e.Players,
+		Dealt:      e.Dealt,
+		Timestamp:  e.Timestamp,
+	}
+	if e.Card != nil {
+		eventDTO.Card = &CardDTO{
+			Rank: e.Card.Rank,
+			Suit: e.Card.Suit,
 		}
+	}
+	return eventDTO
+}
+
+func ToHistoryPageDTO(page game.HistoryPage) HistoryPageDTO {
+	dto := HistoryPageDTO{
+		Events:     make([]EventDTO, 0, len(page.Events)),
+		NextCursor: page.NextCursor,
+	}
+	for _, e := range page.Events {
+		dto.Events = append(dto.Events, ToEventDTO(e))
+	}
+	return dto
+}
+
+func (f HistoryFilterDTO) Query(cursor int64, limit int) game.HistoryQuery {
+	q := game.HistoryQuery{
+		After:    cursor,
+		Limit:    limit,
+		PlayerID: f.PlayerID,
+		Since:    f.Since,
+		Until:    f.Until,
+	}
+	for _, t := range f.Types {
+		q.Types = append(q.Types, game.EventType(t))
+	}
+	return q
+}
+
+func toActionDTO(a *game.Action) *ActionDTO {
+	if a == nil {
+		return nil
+	}
+
+	dto := &ActionDTO{
+		ID:       a.ID,
+		PlayerID: a.PlayerID,
+		Type:     string(a.Type),
+	}
 
-		state := ServerMessage{
-			Type: "GAME_STATE",
-			Payload: toPlayerGameState(g, client.PlayerID),
+	for pid := range a.ChallengedBy {
+		dto.ChallengedBy = append(dto.ChallengedBy, pid)
+	}
+
+	for pid := range a.AcceptedBy {
+		dto.AcceptedBy = append(dto.AcceptedBy, pid)
+	}
+
+	if a.Card != nil {
+		dto.Card = &CardDTO{
+			Rank: a.Card.Rank,
+			Suit: a.Card.Suit,
 		}
+	}
+
+	if a.Resolved {
+		dto.Resolution = string(a.Resolution)
+	}
 
-		client.Conn.SetWriteDeadline(time.Now().Add(writeWait))
-		if err := client.Conn.WriteJSON(state); err != nil {
+	return dto
+}
+
+func toRoundResultDTOs(g *game.Game) []RoundResultDTO {
+	var results []RoundResultDTO
+	for _, r := range g.Session.Results {
+		results = append(results, RoundResultDTO{
+			Round:    r.Round,
+			WinnerID: r.WinnerID,
+			EndedAt:  r.EndedAt.Unix(),
+		})
+	}
+	return results
+}
+
+func toStandingDTOs(g *game.Game) []StandingDTO {
+	var standings []StandingDTO
+	for _, s := range g.Standings() {
+		standings = append(standings, StandingDTO{
+			PlayerID:             s.PlayerID,
+			RoundWins:            s.RoundWins,
+			PenaltyCards:         s.PenaltyCards,
+			SuccessfulChallenges: s.SuccessfulChallenges,
+			RejectedPlays:        s.RejectedPlays,
+		})
+	}
+	return standings
+}
+
+func (h *Handler) broadcastMessage(gameID string, msg ServerMessage) {
+	for _, client := range h.gameClients(gameID) {
+		if err := client.Send(msg); err != nil {
+			log.Printf("broadcast failed to %s: %v", client.PlayerID, err)
+		}
+	}
+}
+
+// broadcastGameState moves a game to its next state version and sends each
+// client a STATE_PATCH against what it last received.
+func (h *Handler) broadcastGameState(gameID string, g *game.Game) {
+	h.nextStateVersion(gameID)
+
+	for _, client := range h.gameClients(gameID) {
+		if err := h.sendState(client, g, true); err != nil {
 			log.Printf("broadcast failed to %s: %v", client.PlayerID, err)
 		}
 	}
 }
+
+
+// replaceSeat unbinds and closes every other client holding a seat, once
+// its player has reclaimed it from keep.
+func (h *Handler) replaceSeat(gameID, playerID string, keep *Client) {
+	var stale []*Client
+	for _, client := range h.gameClients(gameID) {
+		if client != keep && client.PlayerID == playerID {
+			client.bind("", "")
+			client.forgetState()
+			stale = append(stale, client)
+		}
+	}
+	for _, client := range stale {
+		client.Conn.Close()
+	}
+}
+
+// disconnectPlayer tells a removed player's clients and detaches them from
+// the game; the connections stay open so they can join elsewhere.
+func (h *Handler) disconnectPlayer(gameID, playerID string) {
+	for _, client := range h.gameClients(gameID) {
+		if client.PlayerID != playerID {
+			continue
+		}
+
+		if err := client.Send(ServerMessage{Type: "KICKED"}); err != nil {
+			log.Printf("kick notice failed to %s: %v", playerID, err)
+		}
+		client.bind("", "")
+	}
+}
@@ -0,0 +1,168 @@
+package ws
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/JemJasonCorraggio/mao/internal/game"
+)
+
+// recordingConn keeps every message written to it.
+type recordingConn struct {
+	mu     sync.Mutex
+	msgs   []ServerMessage
+	closed bool
+}
+
+func (c *recordingConn) Write(msg ServerMessage) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.msgs = append(c.msgs, msg)
+	return nil
+}
+
+func (c *recordingConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+// last returns the most recent message of type typ.
+func (c *recordingConn) last(t *testing.T, typ string) ServerMessage {
+	t.Helper()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := len(c.msgs) - 1; i >= 0; i-- {
+		if c.msgs[i].Type == typ {
+			return c.msgs[i]
+		}
+	}
+	t.Fatalf("no %s message", typ)
+	return ServerMessage{}
+}
+
+func newTestHandler(t *testing.T, options Options) (*Handler, *game.Registry) {
+	t.Helper()
+
+	games := game.NewRegistry(game.DefaultConfig(), game.NewMemoryStore())
+	return NewHandler(options, games), games
+}
+
+// connect attaches a client that has negotiated acks and any other
+// capabilities given.
+func connect(t *testing.T, h *Handler, capabilities ...string) (*Client, *recordingConn) {
+	t.Helper()
+
+	conn := &recordingConn{}
+	c := h.Attach(conn, "192.0.2.1")
+	t.Cleanup(func() { h.Detach(c) })
+	send(t, h, c, map[string]interface{}{"type": "HELLO", "protocolVersion": ProtocolVersion, "capabilities": append([]string{CapAcks}, capabilities...)})
+	return c, conn
+}
+
+func send(t *testing.T, h *Handler, c *Client, msg map[string]interface{}) {
+	t.Helper()
+
+	b, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Dispatch(c, b); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSeatedMessagesActOnOwnGame(t *testing.T) {
+	h, games := newTestHandler(t, DefaultOptions())
+	victim, _ := connect(t, h)
+	send(t, h, victim, map[string]interface{}{"type": "CREATE_GAME", "name": "ann"})
+	target := victim.GameID
+	bob, _ := connect(t, h)
+	send(t, h, bob, map[string]interface{}{"type": "JOIN_GAME", "gameId": target, "name": "bob"})
+
+	// an attacker seated as "ann" in a game of their own
+	attacker, attackerConn := connect(t, h)
+	send(t, h, attacker, map[string]interface{}{"type": "CREATE_GAME", "name": "ann"})
+	unseated, unseatedConn := connect(t, h)
+
+	tests := []struct {
+		name string
+		c    *Client
+		conn *recordingConn
+		msg  map[string]interface{}
+	}{
+		{name: "start another game", c: attacker, conn: attackerConn, msg: map[string]interface{}{"type": "START_GAME", "gameId": target, "requestId": "1"}},
+		{name: "start unseated", c: unseated, conn: unseatedConn, msg: map[string]interface{}{"type": "START_GAME", "gameId": target, "requestId": "3"}},
+		{name: "draw unseated", c: unseated, conn: unseatedConn, msg: map[string]interface{}{"type": "PROPOSE_DRAW", "gameId": target, "requestId": "4"}},
+		{name: "draw without a game", c: unseated, conn: unseatedConn, msg: map[string]interface{}{"type": "PROPOSE_DRAW", "requestId": "5"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			send(t, h, tt.c, tt.msg)
+			got := tt.conn.last(t, "ERROR").Payload.(ErrorDTO)
+			if got.RequestID != tt.msg["requestId"] {
+				t.Errorf("last ERROR is for %q, want %q", got.RequestID, tt.msg["requestId"])
+			}
+		})
+	}
+
+	g, err := games.GetGame(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Lock()
+	defer g.Unlock()
+	if g.Status != game.GameWaiting || g.CurrentAction != nil {
+		t.Errorf("victim game changed: status %s, current action %+v", g.Status, g.CurrentAction)
+	}
+}
+
+func TestHistoryIsPublic(t *testing.T) {
+	h, _ := newTestHandler(t, DefaultOptions())
+	ann, _ := connect(t, h)
+	send(t, h, ann, map[string]interface{}{"type": "CREATE_GAME", "name": "ann"})
+	send(t, h, ann, map[string]interface{}{"type": "START_GAME", "gameId": ann.GameID})
+
+	// a spectator who only knows the code
+	spectator, conn := connect(t, h)
+	send(t, h, spectator, map[string]interface{}{"type": "GET_HISTORY", "gameId": ann.GameID, "requestId": "1"})
+
+	page := conn.last(t, "HISTORY").Payload.(HistoryPageDTO)
+	if len(page.Events) == 0 {
+		t.Error("spectator got an empty history")
+	}
+}
+
+func TestHandlersKeepOwnState(t *testing.T) {
+	first, games := newTestHandler(t, DefaultOptions())
+	options := DefaultOptions()
+	options.MaxGamesPerIP = 1
+	second := NewHandler(options, games)
+
+	ann, _ := connect(t, first)
+	send(t, first, ann, map[string]interface{}{"type": "CREATE_GAME", "name": "ann"})
+	bob, _ := connect(t, second)
+	send(t, second, bob, map[string]interface{}{"type": "JOIN_GAME", "gameId": ann.GameID, "name": "bob"})
+
+	for _, tt := range []struct {
+		name string
+		h    *Handler
+		want *Client
+	}{
+		{name: "first", h: first, want: ann},
+		{name: "second", h: second, want: bob},
+	} {
+		clients := tt.h.gameClients(ann.GameID)
+		if len(clients) != 1 || clients[0] != tt.want {
+			t.Errorf("%s handler has clients %v, want only its own", tt.name, clients)
+		}
+	}
+	// only the second handler broadcast the join
+	if first.stateVersion(ann.GameID) != 0 || second.stateVersion(ann.GameID) != 1 {
+		t.Errorf("state versions %d and %d, want 0 and 1", first.stateVersion(ann.GameID), second.stateVersion(ann.GameID))
+	}
+}
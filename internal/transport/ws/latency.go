@@ -0,0 +1,122 @@
+package ws
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	goodRTT = 150 * time.Millisecond
+	fairRTT = 400 * time.Millisecond
+
+	// rttSmoothing weighs each new round trip against the running average,
+	// so one slow ping does not mark a player as lagging.
+	rttSmoothing = 0.25
+)
+
+// Connection quality as shown to the table. STALE means no round trip was
+// measured recently, which usually means the connection is stuck.
+const (
+	QualityGood  = "GOOD"
+	QualityFair  = "FAIR"
+	QualityPoor  = "POOR"
+	QualityStale = "STALE"
+)
+
+type PingMessage struct {
+	Type       string `json:"type"`
+	ClientTime int64  `json:"clientTime"`
+	// RTT is the round trip the client measured from its previous PONG, in
+	// milliseconds.
+	RTT int64 `json:"rtt,omitempty"`
+}
+
+type PongDTO struct {
+	ClientTime int64 `json:"clientTime"`
+	ServerTime int64 `json:"serverTime"`
+}
+
+type ConnectionDTO struct {
+	RTTMillis    int64  `json:"rttMs"`
+	OffsetMillis int64  `json:"offsetMs"`
+	Quality      string `json:"quality" ts:"ConnectionQuality"`
+}
+
+// latency tracks one connection's round trip time and how far the client's
+// clock is ahead of the server's.
+type latency struct {
+	// staleAfter is how long a measurement is trusted.
+	staleAfter time.Duration
+
+	mu       sync.Mutex
+	rtt      time.Duration
+	offset   time.Duration
+	measured time.Time
+}
+
+func (l *latency) observeRTT(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rtt == 0 {
+		l.rtt = d
+	} else {
+		l.rtt += time.Duration(rttSmoothing * float64(d-l.rtt))
+	}
+	l.measured = time.Now()
+}
+
+// observeClock estimates the clock offset from a client timestamp, assuming
+// the message took half a round trip to arrive.
+func (l *latency) observeClock(clientTime, received time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.offset = clientTime.Sub(received.Add(-l.rtt / 2))
+}
+
+func (l *latency) snapshot(now time.Time) *ConnectionDTO {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.measured.IsZero() {
+		return nil
+	}
+
+	dto := &ConnectionDTO{
+		RTTMillis:    l.rtt.Milliseconds(),
+		OffsetMillis: l.offset.Milliseconds(),
+	}
+	switch {
+	case now.Sub(l.measured) > l.staleAfter:
+		dto.Quality = QualityStale
+	case l.rtt < goodRTT:
+		dto.Quality = QualityGood
+	case l.rtt < fairRTT:
+		dto.Quality = QualityFair
+	default:
+		dto.Quality = QualityPoor
+	}
+	return dto
+}
+
+// connectionOf reports the best connection a player has open to a game, or
+// nil if none has been measured yet.
+func (h *Handler) connectionOf(gameID, playerID string) *ConnectionDTO {
+	var best *ConnectionDTO
+	now := time.Now()
+	for _, c := range h.gameClients(gameID) {
+		if c.PlayerID != playerID {
+			continue
+		}
+		conn := c.latency.snapshot(now)
+		if conn != nil && (best == nil || conn.RTTMillis < best.RTTMillis) {
+			best = conn
+		}
+	}
+	return best
+}
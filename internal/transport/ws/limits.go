@@ -0,0 +1,217 @@
+package ws
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JemJasonCorraggio/mao/internal/game"
+	"github.com/JemJasonCorraggio/mao/internal/ratelimit"
+)
+
+// Message classes share a rate limit. Control messages are never limited.
+const (
+	ClassControl = "control"
+	ClassCreate  = "create"
+	ClassJoin    = "join"
+	ClassPlay    = "play"
+	ClassAdmin   = "admin"
+	ClassQuery   = "query"
+)
+
+// limitedClasses are the classes limits may be configured for.
+var limitedClasses = map[string]bool{
+	ClassCreate: true,
+	ClassJoin:   true,
+	ClassPlay:   true,
+	ClassAdmin:  true,
+	ClassQuery:  true,
+}
+
+func defaultConnLimits() map[string]ratelimit.Limit {
+	return map[string]ratelimit.Limit{
+		ClassCreate: ratelimit.Per(3, 30*time.Second),
+		ClassJoin:   ratelimit.Per(5, 5*time.Second),
+		ClassPlay:   ratelimit.Per(10, 2*time.Second),
+		ClassAdmin:  ratelimit.Per(20, 4*time.Second),
+		ClassQuery:  ratelimit.Per(10, 5*time.Second),
+	}
+}
+
+func defaultIPLimits() map[string]ratelimit.Limit {
+	return map[string]ratelimit.Limit{
+		ClassCreate: ratelimit.Per(5, time.Minute),
+		ClassJoin:   ratelimit.Per(20, 10*time.Second),
+		ClassPlay:   ratelimit.Per(50, 2*time.Second),
+		ClassAdmin:  ratelimit.Per(50, 2*time.Second),
+		ClassQuery:  ratelimit.Per(30, 5*time.Second),
+	}
+}
+
+// limits throttles message classes per connection and per IP, and caps
+// how many unfinished games one IP may hold.
+type limits struct {
+	games         *game.Registry
+	clock         ratelimit.Clock
+	connLimits    map[string]ratelimit.Limit
+	ipLimiters    map[string]*ratelimit.Limiter
+	maxGamesPerIP int
+
+	mu     sync.Mutex
+	owners map[string][]string
+}
+
+func newLimits(options Options, games *game.Registry) *limits {
+	clock := options.Clock
+	if clock == nil {
+		clock = ratelimit.SystemClock{}
+	}
+
+	l := &limits{
+		games:         games,
+		clock:         clock,
+		connLimits:    options.ConnLimits,
+		ipLimiters:    make(map[string]*ratelimit.Limiter),
+		maxGamesPerIP: options.MaxGamesPerIP,
+		owners:        make(map[string][]string),
+	}
+	for class, limit := range options.IPLimits {
+		l.ipLimiters[class] = ratelimit.New(limit, clock)
+	}
+	return l
+}
+
+func throttled(retryAfter time.Duration) error {
+	return &requestError{
+		msg:        "too many requests",
+		code:       "RATE_LIMITED",
+		retryAfter: retryAfter,
+	}
+}
+
+// allowIP spends a token from the IP's bucket for a class.
+func (l *limits) allowIP(ip, class string) error {
+	limiter, ok := l.ipLimiters[class]
+	if !ok {
+		return nil
+	}
+	if allowed, wait := limiter.Allow(ip); !allowed {
+		return throttled(wait)
+	}
+	return nil
+}
+
+// allow checks a client's own bucket for the class before its IP's, so a
+// single noisy connection is stopped before it drains its neighbours.
+func (l *limits) allow(c *Client, class string) error {
+	if limit, ok := l.connLimits[class]; ok {
+		now := l.clock.Now()
+		if c.buckets == nil {
+			c.buckets = make(map[string]*ratelimit.Bucket)
+		}
+		b, exists := c.buckets[class]
+		if !exists {
+			b = ratelimit.NewBucket(limit, now)
+			c.buckets[class] = b
+		}
+		if allowed, wait := b.Take(now); !allowed {
+			return throttled(wait)
+		}
+	}
+	return l.allowIP(c.ip, class)
+}
+
+// admitGame refuses a new game while the IP already holds the maximum of
+// games that have not ended.
+func (l *limits) admitGame(ip string) error {
+	if l.maxGamesPerIP <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var active []string
+	for _, id := range l.owners[ip] {
+		g, err := l.games.GetGame(id)
+		if err != nil {
+			continue
+		}
+		g.Lock()
+		ended := g.Status == game.GameEnded
+		g.Unlock()
+		if !ended {
+			active = append(active, id)
+		}
+	}
+	l.owners[ip] = active
+	if len(active) == 0 {
+		delete(l.owners, ip)
+	}
+
+	if len(active) >= l.maxGamesPerIP {
+		return &requestError{msg: "too many active games", code: "TOO_MANY_GAMES"}
+	}
+	return nil
+}
+
+func (l *limits) gameCreated(ip, gameID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.owners[ip] = append(l.owners[ip], gameID)
+}
+
+// gameRemoved stops counting a game that no longer exists against its
+// creator.
+func (l *limits) gameRemoved(gameID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for ip, ids := range l.owners {
+		for i, id := range ids {
+			if id == gameID {
+				ids = append(ids[:i], ids[i+1:]...)
+				break
+			}
+		}
+		if len(ids) == 0 {
+			delete(l.owners, ip)
+		} else {
+			l.owners[ip] = ids
+		}
+	}
+}
+
+// ClientIP is the address limits are applied to: the configured proxy
+// header when set, the connection's remote address otherwise.
+func (h *Handler) ClientIP(r *http.Request) string {
+	if h.options.ClientIPHeader != "" {
+		if ip := strings.TrimSpace(r.Header.Get(h.options.ClientIPHeader)); ip != "" {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Admit applies the per-IP limits to a request made outside a connection,
+// such as over REST, and the game cap when it would create one.
+func (h *Handler) Admit(ip, class string) error {
+	if err := h.limits.allowIP(ip, class); err != nil {
+		return err
+	}
+	if class == ClassCreate {
+		return h.limits.admitGame(ip)
+	}
+	return nil
+}
+
+func (h *Handler) GameCreated(ip, gameID string) {
+	h.limits.gameCreated(ip, gameID)
+}
@@ -0,0 +1,96 @@
+package ws
+
+import (
+	"testing"
+	"time"
+
+	"github.com/JemJasonCorraggio/mao/internal/ratelimit"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func limitedOptions(clock ratelimit.Clock, conn, ip ratelimit.Limit) Options {
+	options := DefaultOptions()
+	options.Clock = clock
+	options.ConnLimits = map[string]ratelimit.Limit{ClassQuery: conn}
+	options.IPLimits = map[string]ratelimit.Limit{ClassQuery: ip}
+	return options
+}
+
+func TestConnLimit(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	h, _ := newTestHandler(t, limitedOptions(clock, ratelimit.Per(2, 2*time.Second), ratelimit.Limit{}))
+	c, conn := connect(t, h)
+	send(t, h, c, map[string]interface{}{"type": "CREATE_GAME", "name": "ann"})
+
+	resync := func(id string) {
+		send(t, h, c, map[string]interface{}{"type": "RESYNC", "requestId": id})
+	}
+	resync("1")
+	resync("2")
+	resync("3")
+
+	got := conn.last(t, "ERROR").Payload.(ErrorDTO)
+	if got.Code != "RATE_LIMITED" || got.RequestID != "3" || got.RetryAfterMillis != 1000 {
+		t.Fatalf("third query = %+v, want RATE_LIMITED for 1s", got)
+	}
+
+	clock.now = clock.now.Add(time.Second)
+	resync("3")
+	if ack := conn.last(t, "ACK").Payload.(AckDTO); ack.RequestID != "3" {
+		t.Errorf("resend after the wait was not applied, last ACK is %+v", ack)
+	}
+}
+
+func TestIPLimitIsShared(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	h, _ := newTestHandler(t, limitedOptions(clock, ratelimit.Limit{}, ratelimit.Per(1, time.Minute)))
+
+	if err := h.Admit("192.0.2.1", ClassQuery); err != nil {
+		t.Fatalf("first request from the IP refused: %v", err)
+	}
+	err := h.Admit("192.0.2.1", ClassQuery)
+	if ErrorCode(err) != "RATE_LIMITED" || RetryAfter(err) != time.Minute {
+		t.Fatalf("second request = %v, want RATE_LIMITED for a minute", err)
+	}
+	if err := h.Admit("192.0.2.2", ClassQuery); err != nil {
+		t.Errorf("another IP was throttled: %v", err)
+	}
+	if err := h.Admit("192.0.2.1", ClassPlay); err != nil {
+		t.Errorf("a class without a limit was throttled: %v", err)
+	}
+
+	clock.now = clock.now.Add(time.Minute)
+	if err := h.Admit("192.0.2.1", ClassQuery); err != nil {
+		t.Errorf("refused once the bucket refilled: %v", err)
+	}
+}
+
+func TestMaxGamesPerIP(t *testing.T) {
+	options := DefaultOptions()
+	options.IPLimits = nil
+	options.MaxGamesPerIP = 1
+	h, _ := newTestHandler(t, options)
+
+	c, conn := connect(t, h)
+	send(t, h, c, map[string]interface{}{"type": "CREATE_GAME", "name": "ann"})
+	send(t, h, c, map[string]interface{}{"type": "CREATE_GAME", "name": "ann", "requestId": "2"})
+
+	if got := conn.last(t, "ERROR").Payload.(ErrorDTO); got.Code != "TOO_MANY_GAMES" {
+		t.Errorf("second game = %+v, want TOO_MANY_GAMES", got)
+	}
+}
+
+func TestValidateRejectsUnknownClass(t *testing.T) {
+	options := DefaultOptions()
+	options.IPLimits["chat"] = ratelimit.Per(1, time.Second)
+	if err := options.Validate(); err == nil {
+		t.Error("Validate accepted a limit for an unknown class")
+	}
+}
@@ -0,0 +1,113 @@
+package ws
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/JemJasonCorraggio/mao/internal/game"
+)
+
+// StatePatch carries the top-level fields of a PlayerGameState that changed
+// since BaseVersion. Set holds the new values keyed by their JSON name;
+// Unset lists optional fields that were dropped. A client whose version is
+// not BaseVersion has missed an update and should send RESYNC.
+type StatePatch struct {
+	BaseVersion int64                      `json:"baseVersion"`
+	Version     int64                      `json:"version"`
+	Set         map[string]json.RawMessage `json:"set,omitempty"`
+	Unset       []string                   `json:"unset,omitempty"`
+}
+
+// stateVersion is the current version of a game's state. It only moves
+// forward, once per broadcast.
+func (h *Handler) stateVersion(gameID string) int64 {
+	h.versionsMu.Lock()
+	defer h.versionsMu.Unlock()
+
+	return h.versions[gameID]
+}
+
+func (h *Handler) nextStateVersion(gameID string) int64 {
+	h.versionsMu.Lock()
+	defer h.versionsMu.Unlock()
+
+	h.versions[gameID]++
+	return h.versions[gameID]
+}
+
+// forgetState drops the last state sent, so the next one is sent in full.
+func (c *Client) forgetState() {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	c.sent = nil
+}
+
+// sendState sends c its view of g. With patch set, when the client
+// negotiated patches and already holds an earlier state of the same game,
+// only the changed fields go out as STATE_PATCH; otherwise the full
+// GAME_STATE is sent.
+func (h *Handler) sendState(c *Client, g *game.Game, patch bool) error {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	_, playerID := c.seat()
+	state := h.ToPlayerGameState(g, playerID)
+
+	msg := ServerMessage{Type: "GAME_STATE", Payload: state}
+	if patch && c.caps[CapStatePatch] && c.sent != nil && c.sent.ID == state.ID && c.sent.PlayerID == state.PlayerID {
+		diff, err := diffState(c.sent, &state)
+		if err != nil {
+			return err
+		}
+		msg = ServerMessage{Type: "STATE_PATCH", Payload: diff}
+	}
+
+	if err := c.Send(msg); err != nil {
+		return err
+	}
+	c.sent = &state
+	return nil
+}
+
+// diffState compares two projections field by field through their JSON
+// encoding, so a patch applies to exactly what the client received.
+func diffState(from, to *PlayerGameState) (StatePatch, error) {
+	patch := StatePatch{
+		BaseVersion: from.Version,
+		Version:     to.Version,
+		Set:         make(map[string]json.RawMessage),
+	}
+
+	before := reflect.ValueOf(*from)
+	after := reflect.ValueOf(*to)
+	t := before.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		name, opts, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" || name == "version" {
+			continue
+		}
+
+		old, err := json.Marshal(before.Field(i).Interface())
+		if err != nil {
+			return StatePatch{}, err
+		}
+		cur, err := json.Marshal(after.Field(i).Interface())
+		if err != nil {
+			return StatePatch{}, err
+		}
+		if bytes.Equal(old, cur) {
+			continue
+		}
+
+		if strings.Contains(opts, "omitempty") && after.Field(i).IsZero() {
+			patch.Unset = append(patch.Unset, name)
+			continue
+		}
+		patch.Set[name] = cur
+	}
+	return patch, nil
+}
@@ -0,0 +1,146 @@
+package ws
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// applyPatch does what a client does with a STATE_PATCH: replace the
+// fields in Set, drop those in Unset and take the new version.
+func applyPatch(t *testing.T, state PlayerGameState, patch StatePatch) map[string]interface{} {
+	t.Helper()
+
+	if state.Version != patch.BaseVersion {
+		t.Fatalf("patch is based on version %d, state is at %d", patch.BaseVersion, state.Version)
+	}
+	out := jsonFields(t, state)
+	for name, raw := range patch.Set {
+		var v interface{}
+		if err := json.Unmarshal(raw, &v); err != nil {
+			t.Fatal(err)
+		}
+		out[name] = v
+	}
+	for _, name := range patch.Unset {
+		delete(out, name)
+	}
+	out["version"] = float64(patch.Version)
+	return out
+}
+
+func jsonFields(t *testing.T, v interface{}) map[string]interface{} {
+	t.Helper()
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestDiffState(t *testing.T) {
+	base := PlayerGameState{
+		ID:       "ABCD",
+		Status:   "ACTIVE",
+		AdminID:  "ann",
+		PlayerID: "ann",
+		Hand:     []CardDTO{{Rank: "A", Suit: "S"}, {Rank: "2", Suit: "H"}},
+		TopCard:  &CardDTO{Rank: "K", Suit: "D"},
+		Round:    1,
+		Version:  4,
+	}
+
+	tests := []struct {
+		name      string
+		change    func(s *PlayerGameState)
+		wantSet   []string
+		wantUnset []string
+	}{
+		{name: "unchanged", change: func(s *PlayerGameState) {}},
+		{
+			name: "fields changed",
+			change: func(s *PlayerGameState) {
+				s.Hand = s.Hand[:1]
+				s.WinnerID = "ann"
+				s.Status = "ENDED"
+			},
+			wantSet: []string{"hand", "status", "winnerId"},
+		},
+		{
+			name:      "optional field dropped",
+			change:    func(s *PlayerGameState) { s.TopCard = nil },
+			wantUnset: []string{"topCard"},
+		},
+		{
+			name:    "required field emptied",
+			change:  func(s *PlayerGameState) { s.Hand = nil },
+			wantSet: []string{"hand"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from := base
+			to := base
+			to.Hand = append([]CardDTO(nil), base.Hand...)
+			to.Version = base.Version + 1
+			tt.change(&to)
+
+			patch, err := diffState(&from, &to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if patch.BaseVersion != 4 || patch.Version != 5 {
+				t.Errorf("versions %d -> %d, want 4 -> 5", patch.BaseVersion, patch.Version)
+			}
+
+			var set []string
+			for name := range patch.Set {
+				set = append(set, name)
+			}
+			sort.Strings(set)
+			if !reflect.DeepEqual(set, tt.wantSet) {
+				t.Errorf("set = %v, want %v", set, tt.wantSet)
+			}
+			if !reflect.DeepEqual(patch.Unset, tt.wantUnset) {
+				t.Errorf("unset = %v, want %v", patch.Unset, tt.wantUnset)
+			}
+
+			if got, want := applyPatch(t, from, patch), jsonFields(t, to); !reflect.DeepEqual(got, want) {
+				t.Errorf("patched state = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestBroadcastSendsPatches(t *testing.T) {
+	h, games := newTestHandler(t, DefaultOptions())
+	ann, annConn := connect(t, h, CapStatePatch)
+	send(t, h, ann, map[string]interface{}{"type": "CREATE_GAME", "name": "ann"})
+	first := annConn.last(t, "GAME_STATE").Payload.(PlayerGameState)
+
+	bob, _ := connect(t, h)
+	send(t, h, bob, map[string]interface{}{"type": "JOIN_GAME", "gameId": first.ID, "name": "bob"})
+
+	patch := annConn.last(t, "STATE_PATCH").Payload.(StatePatch)
+	if _, ok := patch.Set["players"]; !ok {
+		t.Errorf("patch after a join does not carry players: %v", patch.Set)
+	}
+
+	g, err := games.GetGame(first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Lock()
+	want := h.ToPlayerGameState(g, "ann")
+	g.Unlock()
+	if got := applyPatch(t, first, patch); !reflect.DeepEqual(got, jsonFields(t, want)) {
+		t.Errorf("patched state = %v, want %v", got, jsonFields(t, want))
+	}
+}
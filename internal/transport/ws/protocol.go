@@ -0,0 +1,207 @@
+package ws
+
+//go:generate go run ../../../cmd/tsgen -out ../../../web/src/protocol.gen.ts
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ProtocolVersion is the wire protocol this server speaks. Version 1 is the
+// original protocol without a handshake: full GAME_STATE on every change
+// and no replies to client messages. Clients that never send HELLO are
+// treated as version 1.
+const (
+	ProtocolVersion    = 2
+	MinProtocolVersion = 1
+)
+
+// Capabilities are optional protocol features a client opts into in HELLO.
+const (
+	CapStatePatch = "statePatch"
+	CapAcks       = "acks"
+)
+
+type capability struct {
+	Name  string
+	Since int
+}
+
+var capabilities = []capability{
+	{Name: CapStatePatch, Since: 2},
+	{Name: CapAcks, Since: 2},
+}
+
+// MessageSpec describes one message type on the wire. For client messages
+// Body is the struct the message decodes into; for server messages it is
+// the payload. A nil Body means the message carries nothing beyond its
+// type. Capability names the feature a client must have negotiated to
+// receive the message. Class groups client messages that share a rate
+// limit. Seated client messages act as the sender's seat, so they are only
+// accepted from a seated client and for the game it is seated in.
+type MessageSpec struct {
+	Type       string
+	Body       interface{}
+	Capability string
+	Class      string
+	Seated     bool
+}
+
+// clientMessages and serverMessages are the single source of truth for the
+// protocol: Dispatch refuses types not listed here and cmd tooling reads
+// them to describe the protocol to other languages.
+var clientMessages = []MessageSpec{
+	{Type: "HELLO", Body: HelloMessage{}, Class: ClassControl},
+	{Type: "PING", Body: PingMessage{}, Class: ClassControl},
+	{Type: "CREATE_GAME", Body: ClientMessage{}, Class: ClassCreate},
+	{Type: "JOIN_GAME", Body: ClientMessage{}, Class: ClassJoin},
+	{Type: "START_GAME", Body: ClientMessage{}, Class: ClassAdmin, Seated: true},
+	{Type: "PROPOSE_PLAY", Body: ProposePlayCardMessage{}, Class: ClassPlay, Seated: true},
+	{Type: "PROPOSE_DRAW", Body: ProposeDrawMessage{}, Class: ClassPlay, Seated: true},
+	{Type: "ACCEPT_ACTION", Body: AcceptActionMessage{}, Class: ClassPlay, Seated: true},
+	{Type: "CHALLENGE_ACTION", Body: ChallengeActionMessage{}, Class: ClassPlay, Seated: true},
+	{Type: "RESOLVE_ACTION", Body: ResolveActionMessage{}, Class: ClassAdmin, Seated: true},
+	{Type: "ADMIN_PENALIZE", Body: AdminPenaltyMessage{}, Class: ClassAdmin, Seated: true},
+	{Type: "GRANT_PERMISSION", Body: PermissionMessage{}, Class: ClassAdmin, Seated: true},
+	{Type: "REVOKE_PERMISSION", Body: PermissionMessage{}, Class: ClassAdmin, Seated: true},
+	{Type: "KICK_PLAYER", Body: KickPlayerMessage{}, Class: ClassAdmin, Seated: true},
+	{Type: "UPDATE_SETTINGS", Body: UpdateSettingsMessage{}, Class: ClassAdmin, Seated: true},
+	{Type: "ADD_RULE", Body: RuleMessage{}, Class: ClassAdmin, Seated: true},
+	{Type: "EDIT_RULE", Body: RuleMessage{}, Class: ClassAdmin, Seated: true},
+	{Type: "REMOVE_RULE", Body: RuleMessage{}, Class: ClassAdmin, Seated: true},
+	{Type: "SUBMIT_RULE", Body: RuleMessage{}, Class: ClassPlay, Seated: true},
+	{Type: "START_NEXT_ROUND", Body: ClientMessage{}, Class: ClassAdmin, Seated: true},
+	{Type: "END_SESSION", Body: ClientMessage{}, Class: ClassAdmin, Seated: true},
+	{Type: "GET_HISTORY", Body: GetHistoryMessage{}, Class: ClassQuery},
+	{Type: "REPLAY", Body: ReplayMessage{}, Class: ClassQuery},
+	{Type: "REPLAY_STEP", Body: ReplayMessage{}, Class: ClassQuery},
+	{Type: "REPLAY_STOP", Body: ClientMessage{}, Class: ClassControl},
+	{Type: "RESYNC", Body: ClientMessage{}, Class: ClassQuery},
+	{Type: "CALL_APPEAL", Body: ClientMessage{}, Class: ClassPlay, Seated: true},
+	{Type: "APPEAL_VOTE", Body: AppealVoteMessage{}, Class: ClassPlay, Seated: true},
+}
+
+var serverMessages = []MessageSpec{
+	{Type: "WELCOME", Body: WelcomeDTO{}},
+	{Type: "PONG", Body: PongDTO{}},
+	{Type: "GAME_STATE", Body: PlayerGameState{}},
+	{Type: "STATE_PATCH", Body: StatePatch{}, Capability: CapStatePatch},
+	{Type: "ACK", Body: AckDTO{}, Capability: CapAcks},
+	{Type: "ERROR", Body: ErrorDTO{}},
+	{Type: "SESSION_SUMMARY", Body: SessionSummary{}},
+	{Type: "HISTORY", Body: HistoryPageDTO{}},
+	{Type: "REPLAY_FRAME", Body: ReplayFrameDTO{}},
+	{Type: "REPLAY_END"},
+	{Type: "KICKED"},
+	{Type: "GAME_EXPIRED", Body: GameExpiredDTO{}},
+	{Type: "SERVER_SHUTDOWN", Body: ServerShutdownDTO{}},
+}
+
+func ClientMessages() []MessageSpec {
+	return clientMessages
+}
+
+func ServerMessages() []MessageSpec {
+	return serverMessages
+}
+
+// Capabilities lists every capability the server can negotiate.
+func Capabilities() []string {
+	names := make([]string, 0, len(capabilities))
+	for _, c := range capabilities {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+func clientMessageSeated(msgType string) bool {
+	for _, spec := range clientMessages {
+		if spec.Type == msgType {
+			return spec.Seated
+		}
+	}
+	return false
+}
+
+func clientMessageKnown(msgType string) bool {
+	for _, spec := range clientMessages {
+		if spec.Type == msgType {
+			return true
+		}
+	}
+	return false
+}
+
+// messageClass is the rate limit class of a client message. Unknown types
+// count as queries so they cannot be sent for free.
+func messageClass(msgType string) string {
+	for _, spec := range clientMessages {
+		if spec.Type == msgType {
+			return spec.Class
+		}
+	}
+	return ClassQuery
+}
+
+type HelloMessage struct {
+	Type            string   `json:"type"`
+	ProtocolVersion int      `json:"protocolVersion"`
+	Capabilities    []string `json:"capabilities"`
+}
+
+type WelcomeDTO struct {
+	ProtocolVersion int      `json:"protocolVersion"`
+	ServerVersion   int      `json:"serverVersion"`
+	Capabilities    []string `json:"capabilities"`
+	Messages        []string `json:"messages"`
+}
+
+var errUnsupportedProtocol = errors.New("unsupported protocol version")
+
+// negotiate settles the protocol for a client: its own version, if the
+// server still speaks it, and the requested capabilities that version has.
+func (c *Client) negotiate(hello HelloMessage) (WelcomeDTO, error) {
+	if hello.ProtocolVersion < MinProtocolVersion || hello.ProtocolVersion > ProtocolVersion {
+		return WelcomeDTO{}, fmt.Errorf("%w %d: this server speaks %d to %d",
+			errUnsupportedProtocol, hello.ProtocolVersion, MinProtocolVersion, ProtocolVersion)
+	}
+
+	requested := make(map[string]bool)
+	for _, name := range hello.Capabilities {
+		requested[name] = true
+	}
+
+	caps := make(map[string]bool)
+	agreed := []string{}
+	for _, capability := range capabilities {
+		if requested[capability.Name] && capability.Since <= hello.ProtocolVersion {
+			caps[capability.Name] = true
+			agreed = append(agreed, capability.Name)
+		}
+	}
+
+	c.stateMu.Lock()
+	c.caps = caps
+	c.stateMu.Unlock()
+
+	messages := make([]string, 0, len(clientMessages))
+	for _, spec := range clientMessages {
+		messages = append(messages, spec.Type)
+	}
+
+	return WelcomeDTO{
+		ProtocolVersion: hello.ProtocolVersion,
+		ServerVersion:   ProtocolVersion,
+		Capabilities:    agreed,
+		Messages:        messages,
+	}, nil
+}
+
+// supports reports whether the client negotiated a capability. Clients that
+// skipped the handshake have none.
+func (c *Client) supports(name string) bool {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	return c.caps[name]
+}
@@ -0,0 +1,100 @@
+package ws
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestNegotiateRejectsUnsupportedVersion(t *testing.T) {
+	for _, version := range []int{-1, 0, MinProtocolVersion - 1, ProtocolVersion + 1} {
+		c := &Client{}
+		_, err := c.negotiate(HelloMessage{ProtocolVersion: version, Capabilities: []string{CapAcks}})
+		if !errors.Is(err, errUnsupportedProtocol) {
+			t.Errorf("version %d: got %v, want %v", version, err, errUnsupportedProtocol)
+		}
+		if c.supports(CapAcks) {
+			t.Errorf("version %d: refused client still negotiated acks", version)
+		}
+	}
+}
+
+func TestNegotiateDowngradesCapabilities(t *testing.T) {
+	tests := []struct {
+		name      string
+		version   int
+		requested []string
+		want      []string
+	}{
+		{name: "version 1 has no capabilities", version: 1, requested: []string{CapStatePatch, CapAcks}, want: []string{}},
+		{name: "unknown capability dropped", version: 2, requested: []string{CapAcks, "telepathy"}, want: []string{CapAcks}},
+		{name: "nothing requested", version: 2, want: []string{}},
+		{name: "everything requested", version: 2, requested: []string{CapAcks, CapStatePatch}, want: []string{CapStatePatch, CapAcks}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{}
+			welcome, err := c.negotiate(HelloMessage{ProtocolVersion: tt.version, Capabilities: tt.requested})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if welcome.ProtocolVersion != tt.version || welcome.ServerVersion != ProtocolVersion {
+				t.Errorf("welcome speaks %d of %d, want %d of %d", welcome.ProtocolVersion, welcome.ServerVersion, tt.version, ProtocolVersion)
+			}
+			if !reflect.DeepEqual(welcome.Capabilities, tt.want) {
+				t.Errorf("capabilities %v, want %v", welcome.Capabilities, tt.want)
+			}
+			for _, name := range Capabilities() {
+				want := false
+				for _, agreed := range tt.want {
+					want = want || agreed == name
+				}
+				if c.supports(name) != want {
+					t.Errorf("supports(%s) = %v, want %v", name, c.supports(name), want)
+				}
+			}
+		})
+	}
+}
+
+func TestFailedHelloClosesConnection(t *testing.T) {
+	h, _ := newTestHandler(t, DefaultOptions())
+	server := httptest.NewServer(http.HandlerFunc(h.Handle))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	hello := map[string]interface{}{"type": "HELLO", "protocolVersion": ProtocolVersion + 1, "requestId": "1"}
+	if err := conn.WriteJSON(hello); err != nil {
+		t.Fatal(err)
+	}
+
+	var reply struct {
+		Type    string   `json:"type"`
+		Payload ErrorDTO `json:"payload"`
+	}
+	if err := conn.ReadJSON(&reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Type != "ERROR" || reply.Payload.Code != "UNSUPPORTED_PROTOCOL" || reply.Payload.RequestID != "1" {
+		t.Errorf("got %s %+v, want an UNSUPPORTED_PROTOCOL error", reply.Type, reply.Payload)
+	}
+
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Error("connection still open after a failed HELLO")
+	} else if ne, ok := err.(interface{ Timeout() bool }); ok && ne.Timeout() {
+		t.Errorf("server kept the connection open: %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package ws
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/JemJasonCorraggio/mao/internal/game"
+)
+
+const (
+	minReplaySpeed = 0.1
+	maxReplaySpeed = 100
+	// maxReplayDelay keeps long pauses at the table from stalling a replay.
+	maxReplayDelay = 5 * time.Second
+)
+
+type ReplayMessage struct {
+	Type     string  `json:"type"`
+	GameID   string  `json:"gameId"`
+	Speed    float64 `json:"speed,omitempty"`
+	FromStep int     `json:"fromStep,omitempty"`
+	Step     int     `json:"step,omitempty"`
+}
+
+type ReplayFrameDTO struct {
+	Step       int            `json:"step"`
+	Event      EventDTO       `json:"event"`
+	Round      int            `json:"round"`
+	Status     string         `json:"status" ts:"GameStatus"`
+	TopCard    *CardDTO       `json:"topCard,omitempty"`
+	HandCounts map[string]int `json:"handCounts"`
+	Seating    []string       `json:"seating"`
+	WinnerID   string         `json:"winnerId,omitempty"`
+}
+
+func ToReplayFrameDTO(f game.ReplayFrame) ReplayFrameDTO {
+	dto := ReplayFrameDTO{
+		Step:       f.Step,
+		Event:      ToEventDTO(f.Event),
+		Round:      f.Round,
+		Status:     string(f.Status),
+		HandCounts: f.HandCounts,
+		Seating:    f.Seating,
+		WinnerID:   f.WinnerID,
+	}
+	if f.TopCard != nil {
+		dto.TopCard = &CardDTO{
+			Rank: f.TopCard.Rank,
+			Suit: f.TopCard.Suit,
+		}
+	}
+	return dto
+}
+
+// replayRunner keeps at most one replay stream running per connection.
+type replayRunner struct {
+	cancel context.CancelFunc
+}
+
+func (r *replayRunner) start(parent context.Context, stream func(context.Context)) {
+	r.stop()
+	ctx, cancel := context.WithCancel(parent)
+	r.cancel = cancel
+	go stream(ctx)
+}
+
+func (r *replayRunner) stop() {
+	if r.cancel != nil {
+		r.cancel()
+		r.cancel = nil
+	}
+}
+
+// streamReplay sends frames paced by the gaps between their recorded
+// timestamps, divided by speed, and finishes with REPLAY_END.
+func (c *Client) streamReplay(ctx context.Context, frames []game.ReplayFrame, fromStep int, speed float64) {
+	if speed == 0 {
+		speed = 1
+	}
+	if speed < minReplaySpeed {
+		speed = minReplaySpeed
+	}
+	if speed > maxReplaySpeed {
+		speed = maxReplaySpeed
+	}
+	if fromStep < 1 {
+		fromStep = 1
+	}
+
+	for i := fromStep - 1; i < len(frames); i++ {
+		if i > fromStep-1 {
+			gap := time.Duration(frames[i].Event.Timestamp-frames[i-1].Event.Timestamp) * time.Second
+			delay := time.Duration(float64(gap) / speed)
+			if delay > maxReplayDelay {
+				delay = maxReplayDelay
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+		}
+
+		if err := c.Send(ServerMessage{Type: "REPLAY_FRAME", Payload: ToReplayFrameDTO(frames[i])}); err != nil {
+			log.Printf("replay write failed: %v", err)
+			return
+		}
+	}
+
+	if ctx.Err() == nil {
+		if err := c.Send(ServerMessage{Type: "REPLAY_END"}); err != nil {
+			log.Printf("replay write failed: %v", err)
+		}
+	}
+}
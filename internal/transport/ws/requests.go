@@ -0,0 +1,159 @@
+package ws
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// replyTTL bounds how long a reply is kept for resends. It only needs to
+// cover a client reconnecting and flushing what it had not seen answered.
+const replyTTL = 5 * time.Minute
+
+type AckDTO struct {
+	RequestID string `json:"requestId"`
+	Type      string `json:"type"`
+}
+
+type ErrorDTO struct {
+	RequestID string `json:"requestId,omitempty"`
+	Type      string `json:"type,omitempty"`
+	Code      string `json:"code,omitempty"`
+	Message   string `json:"message"`
+	// RetryAfterMillis tells a throttled client when to try again.
+	RetryAfterMillis int64 `json:"retryAfterMs,omitempty"`
+}
+
+// requestError is a message the server refused, as opposed to a failure to
+// reach the client. Code and retryAfter are set when the client can act on
+// them, e.g. when it was throttled.
+type requestError struct {
+	msg        string
+	code       string
+	retryAfter time.Duration
+}
+
+func (e *requestError) Error() string {
+	return e.msg
+}
+
+// ErrorCode returns the machine-readable code of a refused request, if any.
+func ErrorCode(err error) string {
+	var rejected *requestError
+	if errors.As(err, &rejected) {
+		return rejected.code
+	}
+	return ""
+}
+
+// RetryAfter returns how long a throttled client should wait.
+func RetryAfter(err error) time.Duration {
+	var rejected *requestError
+	if errors.As(err, &rejected) {
+		return rejected.retryAfter
+	}
+	return 0
+}
+
+func reject(format string, args ...interface{}) error {
+	return &requestError{msg: fmt.Sprintf(format, args...)}
+}
+
+// requestKey scopes request ids to a seated player, so the same id from a
+// reconnected socket is recognised as a resend.
+type requestKey struct {
+	gameID    string
+	playerID  string
+	requestID string
+}
+
+// cachedReply is the reply to a request, or a marker that the request is
+// still being applied when pending is set.
+type cachedReply struct {
+	msg     ServerMessage
+	at      time.Time
+	pending bool
+}
+
+type replyCache struct {
+	mu      sync.Mutex
+	entries map[requestKey]cachedReply
+	swept   time.Time
+}
+
+func newReplyCache() *replyCache {
+	return &replyCache{entries: make(map[requestKey]cachedReply)}
+}
+
+// replyStatus is what reserve found for a request id.
+type replyStatus int
+
+const (
+	replyNew replyStatus = iota
+	replyPending
+	replyCached
+)
+
+// inProgress answers a resend that arrives while the original is still
+// being applied. Like a throttled message, it may be sent again.
+func inProgress() error {
+	return &requestError{
+		msg:        "request is still being applied",
+		code:       "IN_PROGRESS",
+		retryAfter: time.Second,
+	}
+}
+
+// reserve claims key for the caller, who must then store a reply or
+// release it. If the key is already claimed, the cached reply is returned
+// when there is one.
+func (r *replyCache) reserve(key requestKey) (ServerMessage, replyStatus) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if e, ok := r.entries[key]; ok && now.Sub(e.at) <= replyTTL {
+		if e.pending {
+			return ServerMessage{}, replyPending
+		}
+		return e.msg, replyCached
+	}
+	r.sweep(now)
+	r.entries[key] = cachedReply{at: now, pending: true}
+	return ServerMessage{}, replyNew
+}
+
+// release drops a reservation whose request was not applied, so a resend
+// is tried again.
+func (r *replyCache) release(key requestKey) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.entries[key].pending {
+		delete(r.entries, key)
+	}
+}
+
+func (r *replyCache) store(key requestKey, msg ServerMessage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	r.sweep(now)
+	r.entries[key] = cachedReply{msg: msg, at: now}
+}
+
+// sweep drops expired entries at most once per replyTTL. The caller holds
+// r.mu.
+func (r *replyCache) sweep(now time.Time) {
+	if now.Sub(r.swept) <= replyTTL {
+		return
+	}
+	for k, e := range r.entries {
+		if now.Sub(e.at) > replyTTL {
+			delete(r.entries, k)
+		}
+	}
+	r.swept = now
+}
@@ -0,0 +1,127 @@
+package ws
+
+import (
+	"testing"
+
+	"github.com/JemJasonCorraggio/mao/internal/game"
+)
+
+func TestReplyCacheReserve(t *testing.T) {
+	r := &replyCache{entries: make(map[requestKey]cachedReply)}
+	key := requestKey{gameID: "ABCD", playerID: "ann", requestID: "1"}
+	ack := ServerMessage{Type: "ACK", Payload: AckDTO{RequestID: "1"}}
+
+	if _, status := r.reserve(key); status != replyNew {
+		t.Fatalf("first reserve = %v, want replyNew", status)
+	}
+	if _, status := r.reserve(key); status != replyPending {
+		t.Fatalf("reserve while pending = %v, want replyPending", status)
+	}
+
+	r.release(key)
+	if _, status := r.reserve(key); status != replyNew {
+		t.Fatalf("reserve after release = %v, want replyNew", status)
+	}
+
+	r.store(key, ack)
+	r.release(key)
+	msg, status := r.reserve(key)
+	if status != replyCached || msg.Type != "ACK" {
+		t.Fatalf("reserve after store = %v %+v, want the cached ACK", status, msg)
+	}
+}
+
+func TestDispatchResend(t *testing.T) {
+	h, _ := newTestHandler(t, DefaultOptions())
+	c, conn := connect(t, h)
+	send(t, h, c, map[string]interface{}{"type": "CREATE_GAME", "name": "ann"})
+
+	resync := map[string]interface{}{"type": "RESYNC", "requestId": "r1"}
+	send(t, h, c, resync)
+	send(t, h, c, resync)
+
+	conn.mu.Lock()
+	acks := 0
+	for _, m := range conn.msgs {
+		if m.Type == "ACK" {
+			acks++
+		}
+	}
+	conn.mu.Unlock()
+	if acks != 2 {
+		t.Errorf("got %d ACKs, want the original and the cached one", acks)
+	}
+
+	key := requestKey{gameID: c.GameID, playerID: c.PlayerID, requestID: "r2"}
+	h.replies.reserve(key)
+	send(t, h, c, map[string]interface{}{"type": "RESYNC", "requestId": "r2"})
+
+	got := conn.last(t, "ERROR").Payload.(ErrorDTO)
+	if got.Code != "IN_PROGRESS" || got.RetryAfterMillis == 0 {
+		t.Errorf("resend while in flight = %+v, want IN_PROGRESS with a retry hint", got)
+	}
+	if _, status := h.replies.reserve(key); status != replyPending {
+		t.Errorf("resend while in flight cleared the original's reservation")
+	}
+}
+
+func TestResendAfterReconnectAppliesOnce(t *testing.T) {
+	h, games := newTestHandler(t, DefaultOptions())
+	ann, _ := connect(t, h)
+	send(t, h, ann, map[string]interface{}{"type": "CREATE_GAME", "name": "ann"})
+	gameID := ann.GameID
+
+	old, oldConn := connect(t, h)
+	send(t, h, old, map[string]interface{}{"type": "JOIN_GAME", "gameId": gameID, "name": "bob"})
+	send(t, h, ann, map[string]interface{}{"type": "START_GAME", "gameId": gameID})
+	bob := oldConn.last(t, "GAME_STATE").Payload.(PlayerGameState)
+
+	play := map[string]interface{}{"type": "PROPOSE_PLAY", "gameId": gameID, "requestId": "p1", "card": bob.Hand[0]}
+	send(t, h, old, play)
+
+	// the old socket has not been noticed as gone when the player is back
+	fresh, freshConn := connect(t, h)
+	send(t, h, fresh, map[string]interface{}{"type": "JOIN_GAME", "gameId": gameID, "name": "bob", "token": bob.SeatToken, "requestId": "rejoin"})
+	send(t, h, fresh, play)
+
+	freshConn.mu.Lock()
+	var acks []string
+	for _, m := range freshConn.msgs {
+		if m.Type == "ACK" {
+			acks = append(acks, m.Payload.(AckDTO).RequestID)
+		}
+		if m.Type == "ERROR" {
+			t.Errorf("reconnected client got %+v", m.Payload)
+		}
+	}
+	freshConn.mu.Unlock()
+	if len(acks) != 2 || acks[0] != "rejoin" || acks[1] != "p1" {
+		t.Errorf("ACKs = %v, want the rejoin and the cached play", acks)
+	}
+
+	oldConn.mu.Lock()
+	closed := oldConn.closed
+	oldConn.mu.Unlock()
+	if gameID, playerID := old.seat(); !closed || gameID != "" || playerID != "" {
+		t.Errorf("stale socket kept seat %s/%s, closed %v", gameID, playerID, closed)
+	}
+
+	g, err := games.GetGame(gameID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Lock()
+	defer g.Unlock()
+	if g.CurrentAction == nil || g.CurrentAction.PlayerID != "bob" {
+		t.Fatalf("current action = %+v, want bob's play", g.CurrentAction)
+	}
+	proposals := 0
+	for _, e := range g.RecentEvents {
+		if e.Type == game.EventAction {
+			proposals++
+		}
+	}
+	if proposals != 1 {
+		t.Errorf("play proposed %d times, want once", proposals)
+	}
+}
@@ -0,0 +1,86 @@
+package ws
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ServerShutdownDTO warns clients the server is going away.
+// ReconnectAfterMillis is how soon it expects to be back with their games;
+// without it the games are lost and clients should not rejoin them.
+type ServerShutdownDTO struct {
+	ReconnectAfterMillis int64 `json:"reconnectAfterMs,omitempty"`
+}
+
+// Shutdown stops applying client messages, waiting for those in progress,
+// then tells every client the server is stopping and closes their
+// connections. It gives up on slow clients once ctx is done. A zero
+// reconnectAfter tells clients their games will not come back.
+func (h *Handler) Shutdown(ctx context.Context, reconnectAfter time.Duration) {
+	stopped := make(chan struct{})
+	go func() {
+		h.dispatching.Lock()
+		h.closed = true
+		h.dispatching.Unlock()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		log.Printf("shutdown: gave up waiting on messages in progress")
+	}
+
+	h.clientsMu.RLock()
+	all := make([]*Client, 0, len(h.clients))
+	for c := range h.clients {
+		all = append(all, c)
+	}
+	h.clientsMu.RUnlock()
+
+	msg := ServerMessage{Type: "SERVER_SHUTDOWN", Payload: ServerShutdownDTO{ReconnectAfterMillis: reconnectAfter.Milliseconds()}}
+
+	var wg sync.WaitGroup
+	for _, c := range all {
+		wg.Add(1)
+		go func(c *Client) {
+			defer wg.Done()
+			if err := c.Send(msg); err != nil {
+				log.Printf("shutdown notice failed to %s: %v", c.PlayerID, err)
+			}
+			c.closeGoingAway()
+		}(c)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Printf("shutdown: gave up waiting on slow clients")
+		for _, c := range all {
+			c.Conn.Close()
+		}
+	}
+}
+
+// closeGoingAway ends the connection with a close frame when the transport
+// has one, so clients can tell a restart from a network failure.
+func (c *Client) closeGoingAway() {
+	if w, ok := c.Conn.(wsConn); ok {
+		c.writeMu.Lock()
+		w.conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"),
+			time.Now().Add(w.writeWait))
+		c.writeMu.Unlock()
+	}
+	c.Conn.Close()
+}
@@ -0,0 +1,113 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/JemJasonCorraggio/mao/internal/game"
+)
+
+// blockingConn holds up its writes once armed, standing in for a message
+// that is still being applied.
+type blockingConn struct {
+	recordingConn
+	armed   chan struct{}
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (c *blockingConn) Write(msg ServerMessage) error {
+	select {
+	case <-c.armed:
+		c.entered <- struct{}{}
+		<-c.release
+	default:
+	}
+	return c.recordingConn.Write(msg)
+}
+
+func TestShutdownWaitsForDispatch(t *testing.T) {
+	h, _ := newTestHandler(t, DefaultOptions())
+	conn := &blockingConn{armed: make(chan struct{}), entered: make(chan struct{}), release: make(chan struct{})}
+	c := h.Attach(conn, "192.0.2.1")
+	t.Cleanup(func() { h.Detach(c) })
+
+	close(conn.armed)
+	dispatched := make(chan error, 1)
+	go func() { dispatched <- h.Dispatch(c, []byte(`{"type":"CREATE_GAME","name":"ann"}`)) }()
+	<-conn.entered
+
+	stopped := make(chan struct{})
+	go func() {
+		h.Shutdown(context.Background(), 0)
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		t.Fatal("Shutdown returned while a message was being applied")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	conn.release <- struct{}{}
+	if err := <-dispatched; err != nil {
+		t.Fatal(err)
+	}
+	// the shutdown notice is a write too
+	<-conn.entered
+	conn.release <- struct{}{}
+	<-stopped
+
+	if got := conn.last(t, "SERVER_SHUTDOWN").Payload.(ServerShutdownDTO); got.ReconnectAfterMillis != 0 {
+		t.Errorf("reconnect hint = %dms, want none", got.ReconnectAfterMillis)
+	}
+	if err := h.Dispatch(c, []byte(`{"type":"PING","clientTime":1}`)); !errors.Is(err, game.ErrShuttingDown) {
+		t.Errorf("Dispatch after Shutdown = %v, want ErrShuttingDown", err)
+	}
+}
+
+func TestShutdownAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := game.NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	games := game.NewRegistry(game.DefaultConfig(), store)
+	h := NewHandler(DefaultOptions(), games)
+
+	c, conn := connect(t, h)
+	send(t, h, c, map[string]interface{}{"type": "CREATE_GAME", "name": "ann"})
+	state := conn.last(t, "GAME_STATE").Payload.(PlayerGameState)
+
+	games.Close()
+	h.Shutdown(context.Background(), 5*time.Second)
+	if got := conn.last(t, "SERVER_SHUTDOWN").Payload.(ServerShutdownDTO); got.ReconnectAfterMillis != 5000 {
+		t.Errorf("reconnect hint = %dms, want 5000", got.ReconnectAfterMillis)
+	}
+	// the read loop of a closed socket detaches it
+	h.Detach(c)
+	if err := games.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err = game.NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted := game.NewRegistry(game.DefaultConfig(), store)
+	if n, err := restarted.Restore(); err != nil || n != 1 {
+		t.Fatalf("Restore = %d, %v; want 1 game", n, err)
+	}
+	h = NewHandler(DefaultOptions(), restarted)
+
+	c, conn = connect(t, h)
+	send(t, h, c, map[string]interface{}{"type": "JOIN_GAME", "gameId": state.ID, "name": "ann", "token": state.SeatToken, "requestId": "rejoin"})
+	if ack := conn.last(t, "ACK").Payload.(AckDTO); ack.RequestID != "rejoin" {
+		t.Fatalf("rejoin was not applied, last ACK is %+v", ack)
+	}
+	if got := conn.last(t, "GAME_STATE").Payload.(PlayerGameState); got.ID != state.ID || got.PlayerID != "ann" {
+		t.Errorf("rejoined %s as %s, want %s as ann", got.ID, got.PlayerID, state.ID)
+	}
+}
@@ -94,16 +94,25 @@ function RecentEventsFeed({ events }: { events?: Event[] }) {
                 ⚠️ Penalty: <strong>{e.playerId}</strong> +{e.penalty}
               </span>
             )}
-            {e.type === "ACTION" && e.actionType === "PLAY_CARD" && e.card && (
+            {e.type === "ACTION" && e.resolution !== "REJECT" && e.actionType === "PLAY_CARD" && e.card && (
               <span>
                 🎴 <strong>{e.playerId}</strong> played {e.card.rank} of {e.card.suit}
               </span>
             )}
-            {e.type === "ACTION" && e.actionType === "DRAW" && (
+            {e.type === "ACTION" && e.resolution !== "REJECT" && e.actionType === "DRAW" && (
               <span>
                 🃏 <strong>{e.playerId}</strong> drew a card
               </span>
             )}
+            {e.type === "ACTION" && e.resolution === "REJECT" && (
+              <span style={{ color: "#b00020" }}>
+                🚫 <strong>{e.playerId}</strong> tried to{" "}
+                {e.actionType === "PLAY_CARD" && e.card
+                  ? `play ${e.card.rank} of ${e.card.suit}`
+                  : "draw a card"}{" "}
+                (rejected)
+              </span>
+            )}
             {e.type === "ACTION" && e.actionType === "START_GAME" && (
               <span>🎩 Game started</span>
             )}
@@ -265,6 +274,14 @@ function GameView({ game, send }: { game: PlayerGameState; send: (msg: OutgoingM
                   {isYou && " (You 👤)"}
                   {isDealer && " 🎩 Dealer"}
                   {isWinner && " 🏆"}
+                  {p.connection && p.connection.quality !== "GOOD" && (
+                    <span
+                      title={`${p.connection.rttMs} ms round trip`}
+                      style={{ marginLeft: 8, opacity: 0.7 }}
+                    >
+                      📶 {p.connection.quality.toLowerCase()}
+                    </span>
+                  )}
                 </li>
               );
             })}
@@ -0,0 +1,385 @@
+// Code generated by cmd/tsgen from internal/transport/ws; DO NOT EDIT.
+
+export const PROTOCOL_VERSION = 2;
+export const CAPABILITIES = ["statePatch", "acks"];
+
+export type GameStatus =
+	| "WAITING"
+	| "ACTIVE"
+	| "ENDED";
+
+export type ActionType =
+	| "PLAY_CARD"
+	| "DRAW";
+
+export type ActionResolution =
+	| "ACCEPT"
+	| "ACCEPT_WITH_PENALTY"
+	| "REJECT";
+
+export type Permission =
+	| "RESOLVE_ACTIONS"
+	| "APPLY_PENALTIES"
+	| "START_GAME"
+	| "KICK_PLAYERS";
+
+export type EventType =
+	| "ACTION"
+	| "PENALTY"
+	| "KICK"
+	| "RULE_SUBMITTED"
+	| "RESOLUTION"
+	| "ROUND_WON"
+	| "DISCARD"
+	| "SESSION_ENDED"
+	| "APPEAL_CALLED"
+	| "APPEAL_VOTE"
+	| "APPEAL_CLOSED";
+
+export type ConnectionQuality =
+	| "GOOD"
+	| "FAIR"
+	| "POOR"
+	| "STALE";
+
+export interface AcceptActionMessage {
+	type: string;
+	gameId: string;
+	playerId?: string;
+}
+
+export interface AckDTO {
+	requestId: string;
+	type: string;
+}
+
+export interface ActionDTO {
+	id: string;
+	playerId: string;
+	type: ActionType;
+	card?: CardDTO;
+	challengedBy: string[] | null;
+	acceptedBy: string[] | null;
+	resolution?: ActionResolution;
+}
+
+export interface AdminPenaltyMessage {
+	type: string;
+	gameId: string;
+	targetPlayerId: string;
+	penaltyCount?: number;
+}
+
+export interface AppealDTO {
+	actionId: string;
+	calledBy: string;
+	deadline: number;
+	required: number;
+	overturnBy: string[] | null;
+	upholdBy: string[] | null;
+}
+
+export interface AppealVoteMessage {
+	type: string;
+	gameId: string;
+	overturn: boolean;
+}
+
+export interface CardDTO {
+	rank: string;
+	suit: string;
+}
+
+export interface ChallengeActionMessage {
+	type: string;
+	gameId: string;
+	playerId?: string;
+}
+
+export interface ChallengePolicyDTO {
+	wrongChallengerPenalty: number;
+	wrongAcceptorPenalty: number;
+	correctChallengerDiscard: number;
+}
+
+export interface ClientMessage {
+	type: string;
+	gameId?: string;
+	playerId?: string;
+	name?: string;
+	requestId?: string;
+	token?: string;
+}
+
+export interface ConnectionDTO {
+	rttMs: number;
+	offsetMs: number;
+	quality: ConnectionQuality;
+}
+
+export interface ErrorDTO {
+	requestId?: string;
+	type?: string;
+	code?: string;
+	message: string;
+	retryAfterMs?: number;
+}
+
+export interface EventDTO {
+	seq: number;
+	type: EventType;
+	playerId?: string;
+	actionId?: string;
+	actionType?: string;
+	card?: CardDTO;
+	penalty?: number;
+	discarded?: number;
+	overturn?: boolean;
+	resolution?: ActionResolution;
+	challengers?: string[];
+	players?: string[];
+	dealt?: number;
+	timestamp?: number;
+}
+
+export interface GameExpiredDTO {
+	gameId: string;
+}
+
+export interface GetHistoryMessage {
+	type: string;
+	gameId: string;
+	cursor?: number;
+	limit?: number;
+	filter?: HistoryFilterDTO;
+}
+
+export interface HelloMessage {
+	type: string;
+	protocolVersion: number;
+	capabilities: string[] | null;
+}
+
+export interface HistoryFilterDTO {
+	playerId?: string;
+	types?: EventType[];
+	since?: number;
+	until?: number;
+}
+
+export interface HistoryPageDTO {
+	events: EventDTO[] | null;
+	nextCursor?: number;
+}
+
+export interface KickPlayerMessage {
+	type: string;
+	gameId: string;
+	targetPlayerId: string;
+}
+
+export interface PermissionMessage {
+	type: string;
+	gameId: string;
+	targetPlayerId: string;
+	permission: Permission;
+}
+
+export interface PingMessage {
+	type: string;
+	clientTime: number;
+	rtt?: number;
+}
+
+export interface PlayerGameState {
+	id: string;
+	status: GameStatus;
+	adminId: string;
+	players: PlayerInfo[] | null;
+	hand: CardDTO[] | null;
+	playerId: string;
+	seatToken?: string;
+	currentAction?: ActionDTO;
+	topCard?: CardDTO;
+	lastAction?: ActionDTO;
+	lastRejectedAction?: ActionDTO;
+	winnerId?: string;
+	recentEvents?: EventDTO[];
+	settings: SettingsDTO;
+	appeal?: AppealDTO;
+	rulebook?: RuleDTO[];
+	round: number;
+	roundResults?: RoundResultDTO[];
+	pendingRuleFrom?: string;
+	standings: StandingDTO[] | null;
+	sessionEnded?: boolean;
+	version: number;
+}
+
+export interface PlayerInfo {
+	id: string;
+	handCount: number;
+	isAdmin: boolean;
+	permissions?: Permission[];
+	connection?: ConnectionDTO;
+}
+
+export interface PongDTO {
+	clientTime: number;
+	serverTime: number;
+}
+
+export interface ProposeDrawMessage {
+	type: string;
+	gameId: string;
+	playerId?: string;
+}
+
+export interface ProposePlayCardMessage {
+	type: string;
+	gameId: string;
+	playerId?: string;
+	card: CardDTO;
+}
+
+export interface ReplayFrameDTO {
+	step: number;
+	event: EventDTO;
+	round: number;
+	status: GameStatus;
+	topCard?: CardDTO;
+	handCounts: Record<string, number> | null;
+	seating: string[] | null;
+	winnerId?: string;
+}
+
+export interface ReplayMessage {
+	type: string;
+	gameId: string;
+	speed?: number;
+	fromStep?: number;
+	step?: number;
+}
+
+export interface ResolveActionMessage {
+	type: string;
+	gameId: string;
+	resolution: ActionResolution;
+	penaltyCount?: number;
+}
+
+export interface RoundResultDTO {
+	round: number;
+	winnerId: string;
+	endedAt: number;
+}
+
+export interface RuleDTO {
+	id: string;
+	text: string;
+	createdAt: number;
+	addedBy: string;
+}
+
+export interface RuleMessage {
+	type: string;
+	gameId: string;
+	ruleId?: string;
+	text?: string;
+}
+
+export interface ServerShutdownDTO {
+	reconnectAfterMs?: number;
+}
+
+export interface SessionSummary {
+	gameId: string;
+	rounds: RoundResultDTO[] | null;
+	standings: StandingDTO[] | null;
+}
+
+export interface SettingsDTO {
+	appealsEnabled: boolean;
+	appealWindowSeconds: number;
+	appealMajority: number;
+	revealRulebookOnEnd: boolean;
+	challengePolicy: ChallengePolicyDTO;
+}
+
+export interface StandingDTO {
+	playerId: string;
+	roundWins: number;
+	penaltyCards: number;
+	successfulChallenges: number;
+	rejectedPlays: number;
+}
+
+export interface StatePatch {
+	baseVersion: number;
+	version: number;
+	set?: Record<string, unknown>;
+	unset?: string[];
+}
+
+export interface UpdateSettingsMessage {
+	type: string;
+	gameId: string;
+	appealsEnabled: boolean;
+	appealWindowSeconds: number;
+	appealMajority: number;
+	revealRulebookOnEnd: boolean;
+	challengePolicy: ChallengePolicyDTO;
+}
+
+export interface WelcomeDTO {
+	protocolVersion: number;
+	serverVersion: number;
+	capabilities: string[] | null;
+	messages: string[] | null;
+}
+
+// Every client message may carry a requestId, answered by ACK or ERROR.
+export type OutgoingMessage =
+	| ({ type: "HELLO"; requestId?: string } & Omit<HelloMessage, "type" | "requestId">)
+	| ({ type: "PING"; requestId?: string } & Omit<PingMessage, "type" | "requestId">)
+	| ({ type: "CREATE_GAME"; requestId?: string } & Omit<ClientMessage, "type" | "requestId">)
+	| ({ type: "JOIN_GAME"; requestId?: string } & Omit<ClientMessage, "type" | "requestId">)
+	| ({ type: "START_GAME"; requestId?: string } & Omit<ClientMessage, "type" | "requestId">)
+	| ({ type: "PROPOSE_PLAY"; requestId?: string } & Omit<ProposePlayCardMessage, "type" | "requestId">)
+	| ({ type: "PROPOSE_DRAW"; requestId?: string } & Omit<ProposeDrawMessage, "type" | "requestId">)
+	| ({ type: "ACCEPT_ACTION"; requestId?: string } & Omit<AcceptActionMessage, "type" | "requestId">)
+	| ({ type: "CHALLENGE_ACTION"; requestId?: string } & Omit<ChallengeActionMessage, "type" | "requestId">)
+	| ({ type: "RESOLVE_ACTION"; requestId?: string } & Omit<ResolveActionMessage, "type" | "requestId">)
+	| ({ type: "ADMIN_PENALIZE"; requestId?: string } & Omit<AdminPenaltyMessage, "type" | "requestId">)
+	| ({ type: "GRANT_PERMISSION"; requestId?: string } & Omit<PermissionMessage, "type" | "requestId">)
+	| ({ type: "REVOKE_PERMISSION"; requestId?: string } & Omit<PermissionMessage, "type" | "requestId">)
+	| ({ type: "KICK_PLAYER"; requestId?: string } & Omit<KickPlayerMessage, "type" | "requestId">)
+	| ({ type: "UPDATE_SETTINGS"; requestId?: string } & Omit<UpdateSettingsMessage, "type" | "requestId">)
+	| ({ type: "ADD_RULE"; requestId?: string } & Omit<RuleMessage, "type" | "requestId">)
+	| ({ type: "EDIT_RULE"; requestId?: string } & Omit<RuleMessage, "type" | "requestId">)
+	| ({ type: "REMOVE_RULE"; requestId?: string } & Omit<RuleMessage, "type" | "requestId">)
+	| ({ type: "SUBMIT_RULE"; requestId?: string } & Omit<RuleMessage, "type" | "requestId">)
+	| ({ type: "START_NEXT_ROUND"; requestId?: string } & Omit<ClientMessage, "type" | "requestId">)
+	| ({ type: "END_SESSION"; requestId?: string } & Omit<ClientMessage, "type" | "requestId">)
+	| ({ type: "GET_HISTORY"; requestId?: string } & Omit<GetHistoryMessage, "type" | "requestId">)
+	| ({ type: "REPLAY"; requestId?: string } & Omit<ReplayMessage, "type" | "requestId">)
+	| ({ type: "REPLAY_STEP"; requestId?: string } & Omit<ReplayMessage, "type" | "requestId">)
+	| ({ type: "REPLAY_STOP"; requestId?: string } & Omit<ClientMessage, "type" | "requestId">)
+	| ({ type: "RESYNC"; requestId?: string } & Omit<ClientMessage, "type" | "requestId">)
+	| ({ type: "CALL_APPEAL"; requestId?: string } & Omit<ClientMessage, "type" | "requestId">)
+	| ({ type: "APPEAL_VOTE"; requestId?: string } & Omit<AppealVoteMessage, "type" | "requestId">);
+
+export type ServerMessage =
+	| { type: "WELCOME"; payload: WelcomeDTO }
+	| { type: "PONG"; payload: PongDTO }
+	| { type: "GAME_STATE"; payload: PlayerGameState }
+	| { type: "STATE_PATCH"; payload: StatePatch }
+	| { type: "ACK"; payload: AckDTO }
+	| { type: "ERROR"; payload: ErrorDTO }
+	| { type: "SESSION_SUMMARY"; payload: SessionSummary }
+	| { type: "HISTORY"; payload: HistoryPageDTO }
+	| { type: "REPLAY_FRAME"; payload: ReplayFrameDTO }
+	| { type: "REPLAY_END" }
+	| { type: "KICKED" }
+	| { type: "GAME_EXPIRED"; payload: GameExpiredDTO }
+	| { type: "SERVER_SHUTDOWN"; payload: ServerShutdownDTO };
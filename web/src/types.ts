@@ -1,66 +1,9 @@
-export type GameStatus = "WAITING" | "ACTIVE" | "ENDED";
+// Wire types are generated from the Go DTOs in internal/transport/ws by
+// cmd/tsgen; run `go generate ./...` after changing them. Only names the
+// UI prefers over the Go ones live here.
+export * from "./protocol.gen";
 
-export interface CardDTO {
-	rank: string;
-	suit: string;
-}
+import type { EventDTO, SettingsDTO } from "./protocol.gen";
 
-export type ActionType = "PLAY_CARD" | "DRAW";
-
-export type ActionResolution =
-	| "ACCEPT"
-	| "ACCEPT_WITH_PENALTY"
-	| "REJECT";
-
-export interface ActionDTO {
-	id: string;
-	playerId: string;
-	type: ActionType;
-	card?: CardDTO | null;
-	challengedBy: string[];
-	acceptedBy: string[];
-}
-
-export interface PlayerGameState {
-	id: string;
-	status: GameStatus;
-	adminId: string;
-	players: PlayerInfo[];
-	hand: CardDTO[];
-	playerId: string;
-	currentAction?: ActionDTO | null;
-	topCard?: CardDTO | null;
-	lastAction?: ActionDTO | null;
-	winnerId?: string | null;
-	recentEvents?: Event[];
-}
-
-export interface PlayerInfo {
-	id: string;
-	handCount: number;
-}
-
-export interface Event {
-	type: "ACTION" | "PENALTY";
-	playerId?: string;
-	actionId?: string;
-	actionType?: string;
-	card?: CardDTO | null;
-	penalty?: number;
-	timestamp?: number;
-}
-
-export type OutgoingMessage =
-	| { type: "CREATE_GAME"; name: string }
-	| { type: "JOIN_GAME"; gameId: string; name: string }
-	| { type: "START_GAME"; gameId: string }
-	| { type: "PROPOSE_DRAW"; gameId: string }
-	| { type: "PROPOSE_PLAY"; gameId: string; card: CardDTO }
-	| { type: "ACCEPT_ACTION"; gameId: string }
-	| { type: "CHALLENGE_ACTION"; gameId: string }
-	| { type: "RESOLVE_ACTION"; gameId: string; resolution: ActionResolution; penaltyCount?: number }
-	| { type: "ADMIN_PENALIZE"; gameId: string; targetPlayerId: string; penaltyCount?: number };
-
-export type ServerMessage =
-	| { type: "GAME_STATE"; payload: PlayerGameState }
-	| { type: string; payload?: unknown };
\ No newline at end of file
+export type Event = EventDTO;
+export type Settings = SettingsDTO;
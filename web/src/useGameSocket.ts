@@ -1,27 +1,48 @@
 import { useEffect, useRef, useState } from "react";
-import type { PlayerGameState, OutgoingMessage, ServerMessage } from "./types";
+import { PROTOCOL_VERSION, CAPABILITIES } from "./types";
+import type { PlayerGameState, OutgoingMessage, ServerMessage, StatePatch, ErrorDTO } from "./types";
+
+type Request = OutgoingMessage & { requestId: string };
 
 export function useGameSocket(): {
   connect: () => void;
   send: (message: OutgoingMessage) => void;
   gameState: PlayerGameState | null;
   connected: boolean;
+  lastError: ErrorDTO | null;
 } {
   const socketRef = useRef<WebSocket | null>(null);
   const [gameState, setGameState] = useState<PlayerGameState | null>(null);
   const [connected, setConnected] = useState(false);
+  const [lastError, setLastError] = useState<ErrorDTO | null>(null);
 
   const reconnectAttempt = useRef(0);
   const shouldReconnect = useRef(true);
-  const pendingSends = useRef<OutgoingMessage[]>([]);
+  // sent or queued requests the server has not answered yet, in send order
+  const pendingSends = useRef<Map<string, Request>>(new Map());
   const pingInterval = useRef<number | null>(null);
+  const stateRef = useRef<PlayerGameState | null>(null);
+  const lastRtt = useRef(0);
+  // set by SERVER_SHUTDOWN: how long until the server expects to be back
+  const reconnectHint = useRef<number | null>(null);
 
-  const WS_URL = "wss://mao.fly.dev/ws";
+  // the server that served the page, or the Vite dev server proxying to it
+  const WS_URL = `${location.protocol === "https:" ? "wss:" : "ws:"}//${location.host}/ws`;
 
   function flushQueue() {
-    while (pendingSends.current.length > 0 && socketRef.current?.readyState === WebSocket.OPEN) {
-      const m = pendingSends.current.shift();
-      if (m) socketRef.current?.send(JSON.stringify(m));
+    const socket = socketRef.current;
+    if (socket?.readyState !== WebSocket.OPEN) return;
+
+    socket.send(JSON.stringify({ type: "HELLO", protocolVersion: PROTOCOL_VERSION, capabilities: CAPABILITIES }));
+
+    // a reconnected socket is not seated yet; rejoin with the seat token
+    // before resending so the server recognises requests it already applied
+    const state = stateRef.current;
+    if (state) {
+      socket.send(JSON.stringify({ type: "JOIN_GAME", gameId: state.id, name: state.playerId, token: state.seatToken }));
+    }
+    for (const m of pendingSends.current.values()) {
+      socket.send(JSON.stringify(m));
     }
   }
 
@@ -30,7 +51,7 @@ export function useGameSocket(): {
     pingInterval.current = window.setInterval(() => {
       try {
         if (socketRef.current && socketRef.current.readyState === WebSocket.OPEN) {
-          socketRef.current.send(JSON.stringify({ type: "PING" }));
+          socketRef.current.send(JSON.stringify({ type: "PING", clientTime: Date.now(), rtt: lastRtt.current || undefined }));
         }
       } catch (e) {
 
@@ -49,8 +70,11 @@ export function useGameSocket(): {
     if (!shouldReconnect.current) return;
     reconnectAttempt.current += 1;
     const attempt = reconnectAttempt.current;
-    const base = Math.min(30000, 1000 * Math.pow(2, Math.min(attempt, 6)));
-    const jitter = Math.floor(Math.random() * 400);
+    const hint = reconnectHint.current;
+    reconnectHint.current = null;
+    const base = hint ?? Math.min(30000, 1000 * Math.pow(2, Math.min(attempt, 6)));
+    // after a restart, spread clients out so they do not all return at once
+    const jitter = Math.floor(Math.random() * (hint ? hint / 2 + 400 : 400));
     const delay = base + jitter;
 
     setTimeout(() => {
@@ -58,6 +82,22 @@ export function useGameSocket(): {
     }, delay);
   }
 
+  function applyPatch(patch: StatePatch) {
+    const current = stateRef.current;
+    if (!current || current.version !== patch.baseVersion) {
+      // missed an update; ask for the full state instead of guessing
+      socketRef.current?.send(JSON.stringify({ type: "RESYNC" }));
+      return;
+    }
+
+    const next = { ...current, ...patch.set, version: patch.version } as PlayerGameState;
+    for (const key of patch.unset ?? []) {
+      delete (next as unknown as Record<string, unknown>)[key];
+    }
+    stateRef.current = next;
+    setGameState(next);
+  }
+
   function connect() {
 
     try {
@@ -81,7 +121,49 @@ export function useGameSocket(): {
       try {
           const msg = JSON.parse(event.data) as ServerMessage;
           if (msg.type === "GAME_STATE" && msg.payload) {
-            setGameState(msg.payload as PlayerGameState);
+            stateRef.current = msg.payload as PlayerGameState;
+            setGameState(stateRef.current);
+          } else if (msg.type === "STATE_PATCH" && msg.payload) {
+            applyPatch(msg.payload as StatePatch);
+          } else if (msg.type === "PONG") {
+            lastRtt.current = Date.now() - msg.payload.clientTime;
+          } else if (msg.type === "SERVER_SHUTDOWN") {
+            // the socket closes next; wait for the server to come back
+            reconnectAttempt.current = 0;
+            if (msg.payload.reconnectAfterMs) {
+              reconnectHint.current = msg.payload.reconnectAfterMs;
+            } else {
+              // the game is not saved, so there is nothing to rejoin
+              stateRef.current = null;
+              setGameState(null);
+              pendingSends.current.clear();
+            }
+          } else if (msg.type === "GAME_EXPIRED") {
+            // the code may already belong to a new game; do not rejoin it
+            stateRef.current = null;
+            setGameState(null);
+            pendingSends.current.clear();
+          } else if (msg.type === "ACK") {
+            pendingSends.current.delete(msg.payload.requestId);
+          } else if (msg.type === "ERROR") {
+            const err = msg.payload;
+            const throttled = err.requestId ? pendingSends.current.get(err.requestId) : undefined;
+            if ((err.code === "RATE_LIMITED" || err.code === "IN_PROGRESS") && throttled) {
+              // not applied by this send; try again once allowed, which
+              // returns the original's reply if it has finished by then
+              setTimeout(() => {
+                if (pendingSends.current.has(throttled.requestId)) {
+                  socketRef.current?.send(JSON.stringify(throttled));
+                }
+              }, err.retryAfterMs ?? 1000);
+              return;
+            }
+            if (err.requestId) pendingSends.current.delete(err.requestId);
+            if (err.code === "UNSUPPORTED_PROTOCOL") {
+              // a newer or older server; reconnecting will not help until reload
+              shouldReconnect.current = false;
+            }
+            setLastError(err);
           }
       } catch (e) {
       }
@@ -101,14 +183,14 @@ export function useGameSocket(): {
   }
 
   function send(message: OutgoingMessage) {
+    const request = { ...message, requestId: crypto.randomUUID() } as Request;
+    pendingSends.current.set(request.requestId, request);
     try {
       if (socketRef.current && socketRef.current.readyState === WebSocket.OPEN) {
-        socketRef.current.send(JSON.stringify(message));
-      } else {
-        pendingSends.current.push(message);
+        socketRef.current.send(JSON.stringify(request));
       }
     } catch (e) {
-      pendingSends.current.push(message);
+      // stays pending and is resent on reconnect
     }
   }
 
@@ -126,5 +208,5 @@ export function useGameSocket(): {
     };
   }, []);
 
-  return { connect, send, gameState, connected };
+  return { connect, send, gameState, connected, lastError };
 }
@@ -1,7 +1,19 @@
 import { defineConfig } from 'vite'
 import react from '@vitejs/plugin-react'
 
+// the Go server, which the dev server forwards sockets and API calls to
+const backend = process.env.MAO_BACKEND ?? 'http://localhost:8080'
+
 // https://vite.dev/config/
 export default defineConfig({
   plugins: [react()],
+  server: {
+    // the Host header is passed through unchanged, so the server's
+    // same-origin check accepts the page without -allowed-origins
+    proxy: {
+      '/ws': { target: backend, ws: true },
+      '/api': backend,
+      '/sse': backend,
+    },
+  },
 })